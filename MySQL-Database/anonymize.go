@@ -0,0 +1,36 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// AnonymizeUser erases the personal data of a user for a GDPR erasure
+// request: the username becomes "deleted-user-<id>", and the email,
+// password hash and metadata are cleared, so the account can no longer
+// log in. The row itself stays, so comments and audit entries keep
+// pointing at it. An audit entry is written in the same transaction. It
+// returns an error wrapping ErrUserNotFound if id does not exist.
+func AnonymizeUser(ctx context.Context, db TxBeginner, id int) error {
+	return RunInTx(ctx, db, func(tx *Tx) error {
+		// Checked separately because MySQL counts changed rows, and
+		// anonymizing twice changes nothing.
+		var found int
+		err := tx.QueryRowContext(ctx, `SELECT id FROM users WHERE id = ? FOR UPDATE`, id).Scan(&found)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: id %d", ErrUserNotFound, id)
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE users SET username = ?, email = '', password = '', metadata = NULL WHERE id = ?`,
+			"deleted-user-"+strconv.Itoa(id), id); err != nil {
+			return err
+		}
+		return insertAudit(ctx, tx, id, "anonymize", "")
+	})
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const (
+	lockUserQuery      = `SELECT id FROM users WHERE id = ? FOR UPDATE`
+	anonymizeUserQuery = `UPDATE users SET username = ?, email = '', password = '', metadata = NULL WHERE id = ?`
+)
+
+// The mock fails on any statement it doesn't expect, so these tests also
+// show the user row is updated in place, never deleted, and comments are
+// left alone.
+func TestAnonymizeUser(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockUserQuery).WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectExec(anonymizeUserQuery).WithArgs("deleted-user-3", 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(insertAuditQuery).WithArgs(3, "anonymize", "").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := AnonymizeUser(context.Background(), db, 3); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAnonymizeUserTwice(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockUserQuery).WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	// Nothing left to change: MySQL reports no affected rows.
+	mock.ExpectExec(anonymizeUserQuery).WithArgs("deleted-user-3", 3).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(insertAuditQuery).WithArgs(3, "anonymize", "").WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	if err := AnonymizeUser(context.Background(), db, 3); err != nil {
+		t.Fatalf("second AnonymizeUser = %v, want nil", err)
+	}
+}
+
+func TestAnonymizeUserMissing(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockUserQuery).WithArgs(9).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	if err := AnonymizeUser(context.Background(), db, 9); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("AnonymizeUser error = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestAnonymizeUserAuditFailureRollsBack(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockUserQuery).WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectExec(anonymizeUserQuery).WithArgs("deleted-user-3", 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(insertAuditQuery).WithArgs(3, "anonymize", "").WillReturnError(errors.New("disk full"))
+	mock.ExpectRollback()
+
+	if err := AnonymizeUser(context.Background(), db, 3); err == nil {
+		t.Fatal("AnonymizeUser succeeded though the audit insert failed")
+	}
+}
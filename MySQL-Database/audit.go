@@ -0,0 +1,11 @@
+package database
+
+import "context"
+
+// insertAudit records that actor performed action on the user. It is meant
+// to run in the same transaction as the change it describes.
+func insertAudit(ctx context.Context, db Querier, userID int, action, actor string) error {
+	_, err := db.ExecContext(ctx, `INSERT INTO audit_log (user_id, action, actor) VALUES (?, ?, ?)`,
+		userID, action, actor)
+	return err
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrPoolSaturated is returned when no pooled connection became available
+// within the allowed wait.
+var ErrPoolSaturated = errors.New("database: connection pool saturated")
+
+type connKey struct{}
+
+// AcquireConn takes a connection from the pool, giving up with
+// ErrPoolSaturated if none is free within wait. Cancellation of ctx itself
+// is reported as ctx.Err().
+func AcquireConn(ctx context.Context, db *sql.DB, wait time.Duration) (*sql.Conn, error) {
+	waitCtx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+
+	conn, err := db.Conn(waitCtx)
+	if err != nil {
+		if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+			return nil, ErrPoolSaturated
+		}
+		return nil, err
+	}
+	return conn, nil
+}
+
+// ConnFromContext returns the connection reserved by Backpressure, or nil
+// when the request did not pass through it.
+func ConnFromContext(ctx context.Context) *sql.Conn {
+	conn, _ := ctx.Value(connKey{}).(*sql.Conn)
+	return conn
+}
+
+// Backpressure reserves one pooled connection per request before calling
+// next. When the pool stays exhausted for longer than wait the request is
+// answered with 503 and a Retry-After header instead of queueing behind
+// the others, so callers shed load rather than pile up.
+//
+// Handlers should run their queries on ConnFromContext(r.Context()); the
+// connection is returned to the pool when the handler finishes.
+func Backpressure(db *sql.DB, wait, retryAfter time.Duration) func(http.Handler) http.Handler {
+	return BackpressureFunc(func() *sql.DB { return db }, wait, retryAfter)
+}
+
+// BackpressureFunc is Backpressure for servers that switch databases at
+// runtime, such as on failover: the connection is reserved from the pool
+// returns for each request.
+func BackpressureFunc(pool func() *sql.DB, wait, retryAfter time.Duration) func(http.Handler) http.Handler {
+	retry := strconv.Itoa(int((retryAfter + time.Second - 1) / time.Second))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, err := AcquireConn(r.Context(), pool(), wait)
+			if errors.Is(err, ErrPoolSaturated) {
+				w.Header().Set("Retry-After", retry)
+				http.Error(w, "server busy, retry later", http.StatusServiceUnavailable)
+				return
+			}
+			if err != nil {
+				http.Error(w, "database unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			defer conn.Close()
+
+			ctx := context.WithValue(r.Context(), connKey{}, conn)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
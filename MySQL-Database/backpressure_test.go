@@ -0,0 +1,70 @@
+package database
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBackpressureShedsWhenPoolIsFull(t *testing.T) {
+	db, _ := newMock(t)
+	db.SetMaxOpenConns(1)
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	h := Backpressure(db, 50*time.Millisecond, 2*time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ConnFromContext(r.Context()) == nil {
+			t.Error("handler got no reserved connection")
+		}
+		if r.URL.Path == "/slow" {
+			close(entered)
+			<-release
+		}
+	}))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	slow := httptest.NewRecorder()
+	go func() {
+		defer wg.Done()
+		h.ServeHTTP(slow, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	}()
+	<-entered
+
+	// The only connection is held, so these wait out the 50ms and give up.
+	const excess = 3
+	codes := make([]*httptest.ResponseRecorder, excess)
+	var ewg sync.WaitGroup
+	for i := range codes {
+		codes[i] = httptest.NewRecorder()
+		ewg.Add(1)
+		go func(rec *httptest.ResponseRecorder) {
+			defer ewg.Done()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fast", nil))
+		}(codes[i])
+	}
+	ewg.Wait()
+	for i, rec := range codes {
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("excess request %d: status = %d, want 503", i, rec.Code)
+		}
+		if got := rec.Header().Get("Retry-After"); got != "2" {
+			t.Errorf("excess request %d: Retry-After = %q, want 2", i, got)
+		}
+	}
+
+	close(release)
+	wg.Wait()
+	if slow.Code != http.StatusOK {
+		t.Errorf("request holding the connection: status = %d, want 200", slow.Code)
+	}
+
+	// With the connection back in the pool requests go through again.
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("after release: status = %d, want 200", rec.Code)
+	}
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Comment is a row of the comments table.
+type Comment struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserComments is a user with all of their comments, oldest first.
+type UserComments struct {
+	User
+	Comments []Comment `json:"comments"`
+}
+
+// EachUserWithComments calls fn once per user that isn't deleted, in id
+// order, with that user's comments attached. Users and comments come from a single join
+// that is read row by row, so only one user is held in memory at a time.
+// An error from fn stops the iteration and is returned.
+func EachUserWithComments(ctx context.Context, db Querier, fn func(UserComments) error) error {
+	rows, err := queryRetry(ctx, db, `SELECT u.id, u.username, u.email, u.created_at, c.id, c.body, c.created_at
+		FROM users u LEFT JOIN comments c ON c.user_id = u.id
+		WHERE u.deleted_at IS NULL
+		ORDER BY u.id, c.id`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var (
+		cur     UserComments
+		started bool
+	)
+	for rows.Next() {
+		var (
+			u         User
+			commentID sql.NullInt64
+			body      sql.NullString
+			createdAt sql.NullTime
+		)
+		if err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.CreatedAt, &commentID, &body, &createdAt); err != nil {
+			return err
+		}
+		if !started || u.ID != cur.ID {
+			if started {
+				if err := fn(cur); err != nil {
+					return err
+				}
+			}
+			cur = UserComments{User: u, Comments: []Comment{}}
+			started = true
+		}
+		if commentID.Valid {
+			cur.Comments = append(cur.Comments, Comment{
+				ID:        int(commentID.Int64),
+				UserID:    u.ID,
+				Body:      body.String,
+				CreatedAt: createdAt.Time,
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if started {
+		return fn(cur)
+	}
+	return nil
+}
+
+// Commenter is a user with the number of comments they have written.
+type Commenter struct {
+	User
+	CommentCount int `json:"comment_count"`
+}
+
+// TopCommenters ranks users with at least one comment by how many they
+// have written, most first; users with equal counts are ordered by id so
+// pages don't shift between requests. limit and offset work as in
+// ListUsers.
+func TopCommenters(ctx context.Context, db Querier, limit, offset int) (users []Commenter, hasMore bool, err error) {
+	if limit < 1 || limit > MaxPageSize {
+		limit = DefaultPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	rows, err := queryRetry(ctx, db, `SELECT u.id, u.username, u.password, u.email, u.created_at, COUNT(*) AS n
+		FROM users u JOIN comments c ON c.user_id = u.id
+		WHERE u.deleted_at IS NULL
+		GROUP BY u.id
+		ORDER BY n DESC, u.id
+		LIMIT ? OFFSET ?`, limit+1, offset)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	users = make([]Commenter, 0, limit)
+	for rows.Next() {
+		var c Commenter
+		if err := rows.Scan(&c.ID, &c.Username, &c.Password, &c.Email, &c.CreatedAt, &c.CommentCount); err != nil {
+			return nil, false, err
+		}
+		users = append(users, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+	if len(users) > limit {
+		return users[:limit], true, nil
+	}
+	return users, false, nil
+}
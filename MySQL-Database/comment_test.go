@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// commentJoinRows returns rows shaped like the EachUserWithComments join.
+func commentJoinRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "username", "email", "created_at", "id", "body", "created_at"})
+}
+
+func TestEachUserWithCommentsGroupsByUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery(`FROM users u LEFT JOIN comments c ON c.user_id = u.id\s+WHERE u.deleted_at IS NULL\s+ORDER BY u.id, c.id`).
+		WillReturnRows(commentJoinRows().
+			AddRow(1, "alice", "", now, 10, "first", now).
+			AddRow(1, "alice", "", now, 11, "second", now).
+			AddRow(2, "bob", "", now, nil, nil, nil).
+			AddRow(3, "carol", "", now, 12, "hi", now))
+
+	var got []UserComments
+	err = EachUserWithComments(context.Background(), db, func(u UserComments) error {
+		got = append(got, u)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[int][]int{1: {10, 11}, 2: {}, 3: {12}}
+	if len(got) != len(want) {
+		t.Fatalf("got %d users, want %d", len(got), len(want))
+	}
+	for _, u := range got {
+		ids := want[u.ID]
+		if len(u.Comments) != len(ids) {
+			t.Errorf("user %d has %d comments, want %d", u.ID, len(u.Comments), len(ids))
+			continue
+		}
+		for i, c := range u.Comments {
+			if c.ID != ids[i] || c.UserID != u.ID {
+				t.Errorf("user %d comment %d = %+v, want id %d", u.ID, i, c, ids[i])
+			}
+		}
+	}
+	if got[1].Comments == nil {
+		t.Error("user without comments has nil Comments, want an empty slice")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTopCommentersRanksByCountThenID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Seeded counts: carol 5, alice 3, bob 3, dave 1. The query must rank
+	// by count and break the alice/bob tie by id; the mock answers with
+	// the rows MySQL returns for that ORDER BY.
+	now := time.Now()
+	mock.ExpectQuery(`FROM users u JOIN comments c ON c.user_id = u.id\s+WHERE u.deleted_at IS NULL\s+GROUP BY u.id\s+ORDER BY n DESC, u.id\s+LIMIT \? OFFSET \?`).
+		WithArgs(4, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password", "email", "created_at", "n"}).
+			AddRow(3, "carol", "", "", now, 5).
+			AddRow(1, "alice", "", "", now, 3).
+			AddRow(2, "bob", "", "", now, 3).
+			AddRow(4, "dave", "", "", now, 1))
+
+	users, hasMore, err := TopCommenters(context.Background(), db, 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, u := range users {
+		got = append(got, fmt.Sprintf("%s:%d", u.Username, u.CommentCount))
+	}
+	if want := []string{"carol:5", "alice:3", "bob:3"}; !reflect.DeepEqual(got, want) || !hasMore {
+		t.Errorf("TopCommenters = %v, hasMore %v; want %v and more", got, hasMore, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// DefaultDSN is the connection string used when MYSQL_DSN is not set.
+const DefaultDSN = "root:root@(127.0.0.1:3306)/root?parseTime=true"
+
+// DSNFromEnv returns $MYSQL_DSN, falling back to DefaultDSN.
+func DSNFromEnv() string {
+	if dsn := os.Getenv("MYSQL_DSN"); dsn != "" {
+		return dsn
+	}
+	return DefaultDSN
+}
+
+// OpenDB opens a MySQL handle for dsn and pings it, so a wrong DSN or an
+// unreachable server is reported here rather than on the first query.
+func OpenDB(dsn string) (*sql.DB, error) {
+	return OpenDBWithSQLMode(dsn, "")
+}
+
+// OpenDBWithSQLMode is OpenDB with sql_mode set to mode on every
+// connection; see WithSQLMode. An empty mode keeps the server's default.
+func OpenDBWithSQLMode(dsn, mode string) (*sql.DB, error) {
+	db, err := Open(dsn, mode)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ping MySQL at %s: %w", RedactDSN(dsn), err)
+	}
+	return db, nil
+}
+
+// OpenDBWithRetry is OpenDB for servers that may start before MySQL is
+// ready: it pings up to attempts times as described for PingWithRetry
+// and gives up early when ctx is done.
+func OpenDBWithRetry(ctx context.Context, dsn string, attempts int, backoff time.Duration) (*sql.DB, error) {
+	db, err := Open(dsn, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := PingWithRetry(ctx, db, attempts, backoff); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ping MySQL at %s: %w", RedactDSN(dsn), err)
+	}
+	return db, nil
+}
+
+// Open returns a handle for dsn, with sql_mode set to mode on every
+// connection unless mode is empty. Like sql.Open it doesn't connect; use
+// one of the OpenDB functions, or ping the handle, to find out whether
+// the server is reachable.
+func Open(dsn, mode string) (*sql.DB, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open MySQL: %w", err)
+	}
+	var connector driver.Connector
+	if connector, err = mysql.NewConnector(cfg); err != nil {
+		return nil, fmt.Errorf("open MySQL: %w", err)
+	}
+	if mode != "" {
+		if connector, err = WithSQLMode(connector, mode); err != nil {
+			return nil, err
+		}
+	}
+	return sql.OpenDB(connector), nil
+}
+
+// MaxPingBackoff caps the wait between two attempts of PingWithRetry.
+const MaxPingBackoff = 30 * time.Second
+
+// Pinger is the part of *sql.DB that PingWithRetry needs.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// PingWithRetry pings db up to attempts times, waiting backoff after the
+// first failure and doubling the wait after each further one, up to
+// MaxPingBackoff. It returns nil on the first success, the last ping error
+// if every attempt fails, or ctx.Err() if ctx is done while waiting.
+func PingWithRetry(ctx context.Context, db Pinger, attempts int, backoff time.Duration) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			t := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return fmt.Errorf("%w (last ping: %v)", ctx.Err(), err)
+			case <-t.C:
+			}
+			if backoff *= 2; backoff > MaxPingBackoff {
+				backoff = MaxPingBackoff
+			}
+		}
+		if err = db.PingContext(ctx); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// ServerVersion returns the server's VERSION(), such as "8.0.36".
+func ServerVersion(ctx context.Context, db Querier) (string, error) {
+	var v string
+	err := db.QueryRowContext(ctx, `SELECT VERSION()`).Scan(&v)
+	return v, err
+}
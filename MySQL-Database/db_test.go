@@ -0,0 +1,90 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestOpenDBBadDSN(t *testing.T) {
+	tests := []struct {
+		name string
+		dsn  string
+	}{
+		{"malformed", "not a dsn"},
+		{"unreachable", "app:hunter2@tcp(127.0.0.1:1)/app?timeout=1s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := OpenDB(tt.dsn)
+			if err == nil {
+				db.Close()
+				t.Fatalf("OpenDB(%q) returned no error", tt.dsn)
+			}
+			if db != nil {
+				t.Error("OpenDB returned a handle along with the error")
+			}
+			if strings.Contains(err.Error(), "hunter2") {
+				t.Errorf("error %q contains the password", err)
+			}
+		})
+	}
+}
+
+func TestPingWithRetryFailsTwiceThenSucceeds(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	refused := errors.New("connection refused")
+	mock.ExpectPing().WillReturnError(refused)
+	mock.ExpectPing().WillReturnError(refused)
+	mock.ExpectPing()
+
+	start := time.Now()
+	if err := PingWithRetry(context.Background(), db, 5, 10*time.Millisecond); err != nil {
+		t.Fatalf("PingWithRetry = %v, want nil on the third attempt", err)
+	}
+	// Waits of 10ms then 20ms: the backoff doubles.
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("retried after %v, want at least 30ms of backoff", elapsed)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPingWithRetryGivesUp(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	refused := errors.New("connection refused")
+	mock.ExpectPing().WillReturnError(errors.New("first"))
+	mock.ExpectPing().WillReturnError(refused)
+
+	if err := PingWithRetry(context.Background(), db, 2, time.Millisecond); !errors.Is(err, refused) {
+		t.Errorf("PingWithRetry = %v, want the last ping error", err)
+	}
+}
+
+func TestPingWithRetryCanceled(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := PingWithRetry(ctx, db, 5, time.Hour); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("PingWithRetry = %v, want the context's error", err)
+	}
+}
@@ -0,0 +1,4 @@
+// Package database holds the MySQL access code for the examples: opening
+// and tuning the *sql.DB handle and the queries run against the users
+// table.
+package database
@@ -0,0 +1,16 @@
+package database
+
+import "github.com/go-sql-driver/mysql"
+
+// RedactDSN returns dsn with the password removed, for logs and debug
+// output. An unparsable DSN is redacted entirely.
+func RedactDSN(dsn string) string {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "<invalid DSN>"
+	}
+	if cfg.Passwd != "" {
+		cfg.Passwd = "xxxxx"
+	}
+	return cfg.FormatDSN()
+}
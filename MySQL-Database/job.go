@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Job statuses.
+const (
+	JobPending = "pending"
+	JobClaimed = "claimed"
+)
+
+// Job is a row of the jobs table, a work queue shared by all workers.
+type Job struct {
+	ID        int64
+	Kind      string
+	Payload   string
+	Status    string
+	Worker    string
+	ClaimedAt time.Time
+	CreatedAt time.Time
+}
+
+// EnqueueJob adds a pending job and returns its id.
+func EnqueueJob(ctx context.Context, db Querier, kind, payload string) (int64, error) {
+	res, err := db.ExecContext(ctx, `INSERT INTO jobs (kind, payload) VALUES (?, ?)`, kind, payload)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// ClaimNextJob hands the oldest pending job to worker, or returns nil when
+// none is pending. The row is locked with FOR UPDATE SKIP LOCKED (MySQL
+// 8.0+), so workers claiming concurrently each get a different job instead
+// of waiting on or double-claiming the same one.
+func ClaimNextJob(ctx context.Context, db *sql.DB, worker string) (*Job, error) {
+	var job *Job
+	err := RunInTx(ctx, db, func(tx *Tx) error {
+		var j Job
+		var claimedAt sql.NullTime
+		err := tx.QueryRowContext(ctx, `SELECT id, kind, payload, status, worker, claimed_at, created_at
+			FROM jobs WHERE status = ? ORDER BY id LIMIT 1 FOR UPDATE SKIP LOCKED`, JobPending).
+			Scan(&j.ID, &j.Kind, &j.Payload, &j.Status, &j.Worker, &claimedAt, &j.CreatedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		j.Status, j.Worker, j.ClaimedAt = JobClaimed, worker, time.Now()
+		if _, err := tx.ExecContext(ctx, `UPDATE jobs SET status = ?, worker = ?, claimed_at = ? WHERE id = ?`,
+			j.Status, j.Worker, j.ClaimedAt, j.ID); err != nil {
+			return err
+		}
+		job = &j
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const (
+	claimSelectPattern = `SELECT .+ FROM jobs WHERE status = \? ORDER BY id LIMIT 1 FOR UPDATE SKIP LOCKED`
+	claimUpdatePattern = `UPDATE jobs SET status = \?, worker = \?, claimed_at = \? WHERE id = \?`
+)
+
+var jobColumns = []string{"id", "kind", "payload", "status", "worker", "claimed_at", "created_at"}
+
+func TestClaimNextJobConcurrentWorkers(t *testing.T) {
+	const jobs, workers = 12, 4
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// The database hands each locked row to one transaction; the mock
+	// stands in for that by serving every pending row exactly once, to
+	// whichever worker asks next.
+	mock.MatchExpectationsInOrder(false)
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for id := 1; id <= jobs; id++ {
+		mock.ExpectBegin()
+		mock.ExpectQuery(claimSelectPattern).WithArgs(JobPending).WillReturnRows(
+			sqlmock.NewRows(jobColumns).AddRow(id, "email", fmt.Sprintf(`{"n":%d}`, id), JobPending, "", nil, created))
+		mock.ExpectExec(claimUpdatePattern).WithArgs(JobClaimed, sqlmock.AnyArg(), sqlmock.AnyArg(), id).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+	for i := 0; i < workers; i++ {
+		mock.ExpectBegin()
+		mock.ExpectQuery(claimSelectPattern).WithArgs(JobPending).WillReturnRows(sqlmock.NewRows(jobColumns))
+		mock.ExpectCommit()
+	}
+
+	var (
+		mu      sync.Mutex
+		claimed = map[int64]string{}
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < workers; i++ {
+		worker := fmt.Sprintf("worker-%d", i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				job, err := ClaimNextJob(context.Background(), db, worker)
+				if err != nil {
+					t.Errorf("%s: ClaimNextJob: %v", worker, err)
+					return
+				}
+				if job == nil {
+					return
+				}
+				if job.Status != JobClaimed || job.Worker != worker {
+					t.Errorf("%s: claimed job = %+v", worker, job)
+				}
+				mu.Lock()
+				if prev, ok := claimed[job.ID]; ok {
+					t.Errorf("job %d claimed by both %s and %s", job.ID, prev, worker)
+				}
+				claimed[job.ID] = worker
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(claimed) != jobs {
+		t.Errorf("claimed %d jobs, want %d", len(claimed), jobs)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClaimNextJobNonePending(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectQuery(claimSelectPattern).WithArgs(JobPending).WillReturnRows(sqlmock.NewRows(jobColumns))
+	mock.ExpectCommit()
+
+	job, err := ClaimNextJob(context.Background(), db, "worker-0")
+	if err != nil || job != nil {
+		t.Fatalf("ClaimNextJob = %+v, %v; want nil, nil", job, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+)
+
+// metadataKeyRe is the form of key ListUsersByMetadata accepts. The key
+// becomes part of a JSON path in the query text, so nothing that could
+// end the path or the string literal holding it gets through.
+var metadataKeyRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,63}$`)
+
+// InvalidMetadataKeyError is returned by ListUsersByMetadata for a key that
+// isn't a plain identifier.
+type InvalidMetadataKeyError struct {
+	Key string
+}
+
+func (e *InvalidMetadataKeyError) Error() string {
+	return fmt.Sprintf("database: invalid metadata key %q", e.Key)
+}
+
+// ListUsersByMetadata is ListUsers for the users whose metadata has the
+// top-level field key equal to value. The field is compared as unquoted
+// text, so value "5" matches both the number 5 and the string "5". Users
+// without metadata or without the field never match.
+//
+// key must be a plain identifier. Callers taking it from a request should
+// also check it against the fields they mean to expose.
+func ListUsersByMetadata(ctx context.Context, db Querier, key, value string, limit, offset int) (users []User, hasMore bool, err error) {
+	if !metadataKeyRe.MatchString(key) {
+		return nil, false, &InvalidMetadataKeyError{Key: key}
+	}
+	cond := `JSON_UNQUOTE(JSON_EXTRACT(metadata, '$.` + key + `')) = ?`
+	return listUsersPage(ctx, db, cond, []interface{}{value}, limit, offset)
+}
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// migrations are applied in order, each exactly once. Released entries
+// must not be edited; schema changes are made by appending a new one.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS users (
+		id INT AUTO_INCREMENT,
+		username TEXT NOT NULL,
+		password TEXT NOT NULL,
+		email VARCHAR(255) NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS jobs (
+		id BIGINT AUTO_INCREMENT,
+		kind VARCHAR(64) NOT NULL,
+		payload TEXT NOT NULL,
+		status VARCHAR(16) NOT NULL DEFAULT 'pending',
+		worker VARCHAR(255) NOT NULL DEFAULT '',
+		claimed_at DATETIME NULL,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (id),
+		KEY jobs_status_id (status, id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS audit_log (
+		id BIGINT AUTO_INCREMENT,
+		user_id INT NOT NULL,
+		action VARCHAR(64) NOT NULL,
+		actor VARCHAR(255) NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (id),
+		KEY audit_log_user_id (user_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS comments (
+		id INT AUTO_INCREMENT,
+		user_id INT NOT NULL,
+		body TEXT NOT NULL,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (id),
+		KEY comments_user_id (user_id, id)
+	)`,
+	`ALTER TABLE users ADD COLUMN deleted_at DATETIME NULL`,
+	`ALTER TABLE users ADD COLUMN metadata JSON NULL`,
+	`CREATE TABLE IF NOT EXISTS sessions (
+		token_hash CHAR(64) NOT NULL,
+		user_id INT NOT NULL,
+		expires_at DATETIME NOT NULL,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (token_hash),
+		KEY sessions_expires_at (expires_at)
+	)`,
+	// TEXT columns can't be indexed without a prefix length, so username
+	// becomes a VARCHAR to carry its unique key. Fails if the table already
+	// holds duplicate usernames; those have to be renamed by hand first.
+	`ALTER TABLE users MODIFY username VARCHAR(255) NOT NULL,
+		ADD UNIQUE KEY users_username (username)`,
+}
+
+// Migrate brings the schema up to date. The number of applied migrations
+// is kept in schema_migrations, so calling it again only runs what is new
+// and is safe on every startup.
+//
+// MySQL commits DDL implicitly, so a failing migration is not rolled
+// back; it is retried from the start on the next call.
+func Migrate(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INT NOT NULL,
+		applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (version)
+	)`); err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+
+	var applied int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&applied); err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+
+	for v := applied + 1; v <= len(migrations); v++ {
+		if _, err := db.Exec(migrations[v-1]); err != nil {
+			return fmt.Errorf("migrate: version %d: %w", v, err)
+		}
+		if _, err := db.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, v); err != nil {
+			return fmt.Errorf("migrate: record version %d: %w", v, err)
+		}
+	}
+	return nil
+}
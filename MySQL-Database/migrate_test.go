@@ -0,0 +1,53 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const (
+	createMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INT NOT NULL,
+		applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (version)
+	)`
+	appliedVersion = `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`
+)
+
+func TestMigrateTwice(t *testing.T) {
+	db, mock := newMock(t)
+
+	// First run: a fresh database gets every migration.
+	mock.ExpectExec(createMigrationsTable).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(appliedVersion).WillReturnRows(sqlmock.NewRows([]string{"v"}).AddRow(0))
+	for v, m := range migrations {
+		mock.ExpectExec(m).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`INSERT INTO schema_migrations (version) VALUES (?)`).
+			WithArgs(v + 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	// Second run: everything is recorded, so nothing else runs.
+	mock.ExpectExec(createMigrationsTable).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(appliedVersion).WillReturnRows(sqlmock.NewRows([]string{"v"}).AddRow(len(migrations)))
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("first Migrate: %v", err)
+	}
+	if err := Migrate(db); err != nil {
+		t.Fatalf("second Migrate: %v", err)
+	}
+}
+
+func TestMigrateRunsOnlyNew(t *testing.T) {
+	db, mock := newMock(t)
+	last := len(migrations)
+	mock.ExpectExec(createMigrationsTable).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(appliedVersion).WillReturnRows(sqlmock.NewRows([]string{"v"}).AddRow(last - 1))
+	mock.ExpectExec(migrations[last-1]).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO schema_migrations (version) VALUES (?)`).
+		WithArgs(last).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,34 @@
+package database
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// newMock returns a *sql.DB backed by sqlmock that matches queries
+// exactly, and checks on cleanup that every expectation was met.
+func newMock(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+	return db, mock
+}
+
+// userRows returns the columns scanUser reads, holding users.
+func userRows(users ...User) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "username", "password", "email", "created_at"})
+	for _, u := range users {
+		rows.AddRow(u.ID, u.Username, u.Password, u.Email, u.CreatedAt)
+	}
+	return rows
+}
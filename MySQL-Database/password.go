@@ -0,0 +1,31 @@
+package database
+
+import (
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrPasswordMismatch is returned by CheckPassword when the password does
+// not match the stored hash.
+var ErrPasswordMismatch = errors.New("database: password does not match")
+
+// HashPassword returns the bcrypt hash of plain for storing in the users
+// table.
+func HashPassword(plain string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(plain), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword compares plain with a hash produced by HashPassword. It
+// returns nil on a match and ErrPasswordMismatch otherwise.
+func CheckPassword(hash, plain string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(plain))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrPasswordMismatch
+	}
+	return err
+}
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHashAndCheckPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash == "correct horse" {
+		t.Fatal("HashPassword returned the plaintext")
+	}
+	if err := CheckPassword(hash, "correct horse"); err != nil {
+		t.Errorf("CheckPassword with the right password = %v, want nil", err)
+	}
+	if err := CheckPassword(hash, "battery staple"); !errors.Is(err, ErrPasswordMismatch) {
+		t.Errorf("CheckPassword with a wrong password = %v, want ErrPasswordMismatch", err)
+	}
+}
+
+func TestHashPasswordSalts(t *testing.T) {
+	a, err := HashPassword("same")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := HashPassword("same")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b {
+		t.Error("two hashes of the same password are equal, want distinct salts")
+	}
+}
+
+func TestCheckPasswordMalformedHash(t *testing.T) {
+	err := CheckPassword("not-a-bcrypt-hash", "pw")
+	if err == nil || errors.Is(err, ErrPasswordMismatch) {
+		t.Errorf("CheckPassword with a malformed hash = %v, want a different error", err)
+	}
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Pool defaults used by ApplyPool for fields left at zero.
+const (
+	DefaultMaxOpenConns    = 25
+	DefaultMaxIdleConns    = 5
+	DefaultConnMaxLifetime = 5 * time.Minute
+)
+
+// PoolConfig sizes the connection pool of a *sql.DB. Zero fields take the
+// package defaults.
+type PoolConfig struct {
+	MaxOpen     int
+	MaxIdle     int
+	MaxLifetime time.Duration
+	MaxIdleTime time.Duration
+}
+
+// ApplyPool sets the pool limits in cfg on db. Without them database/sql
+// opens as many connections as there are concurrent queries, which can
+// exhaust the server's max_connections under load.
+func ApplyPool(db *sql.DB, cfg PoolConfig) {
+	if cfg.MaxOpen == 0 {
+		cfg.MaxOpen = DefaultMaxOpenConns
+	}
+	if cfg.MaxIdle == 0 {
+		cfg.MaxIdle = DefaultMaxIdleConns
+	}
+	if cfg.MaxLifetime == 0 {
+		cfg.MaxLifetime = DefaultConnMaxLifetime
+	}
+	if cfg.MaxIdleTime == 0 {
+		cfg.MaxIdleTime = DefaultConnMaxIdleTime
+	}
+
+	db.SetMaxOpenConns(cfg.MaxOpen)
+	db.SetMaxIdleConns(cfg.MaxIdle)
+	db.SetConnMaxLifetime(cfg.MaxLifetime)
+	db.SetConnMaxIdleTime(cfg.MaxIdleTime)
+}
@@ -0,0 +1,23 @@
+package database
+
+import "testing"
+
+func TestApplyPool(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  PoolConfig
+		want int
+	}{
+		{"configured", PoolConfig{MaxOpen: 7}, 7},
+		{"default", PoolConfig{}, DefaultMaxOpenConns},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := newMock(t)
+			ApplyPool(db, tt.cfg)
+			if got := db.Stats().MaxOpenConnections; got != tt.want {
+				t.Errorf("MaxOpenConnections = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
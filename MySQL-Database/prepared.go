@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	userByIDQuery  = `SELECT ` + userColumns + ` FROM users WHERE id = ? AND deleted_at IS NULL`
+	listUsersQuery = `SELECT ` + userColumns + ` FROM users WHERE deleted_at IS NULL ORDER BY id`
+	// The escape character is spelled out because the default, a
+	// backslash, means something else under NO_BACKSLASH_ESCAPES.
+	usersByUsernameQuery = `SELECT ` + userColumns + ` FROM users WHERE username LIKE ? ESCAPE '!' AND deleted_at IS NULL ORDER BY username, id`
+	updateUserQuery      = `UPDATE users SET username = ?, email = ?, password = ? WHERE id = ? AND deleted_at IS NULL`
+	deleteUserQuery      = `UPDATE users SET deleted_at = NOW() WHERE id = ? AND deleted_at IS NULL`
+	hardDeleteUserQuery  = `DELETE FROM users WHERE id = ?`
+)
+
+// preparedUserQueries are the queries NewUserRepository prepares.
+var preparedUserQueries = []string{
+	insertUserQuery,
+	userByIDQuery,
+	listUsersQuery,
+	usersByUsernameQuery,
+	updateUserQuery,
+	deleteUserQuery,
+	hardDeleteUserQuery,
+}
+
+// NewUserRepository returns a UserRepository that prepares its statements
+// once and reuses them, saving the server a parse per call. Close must be
+// called to release them. A UserRepository built as a literal prepares
+// nothing and needs no Close.
+func NewUserRepository(ctx context.Context, db *sql.DB) (*UserRepository, error) {
+	r := &UserRepository{DB: db, stmts: make(map[string]*sql.Stmt, len(preparedUserQueries))}
+	for _, query := range preparedUserQueries {
+		stmt, err := db.PrepareContext(ctx, query)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		r.stmts[query] = stmt
+	}
+	return r, nil
+}
+
+// Close releases the prepared statements. The repository keeps working
+// afterwards, running plain queries.
+func (r *UserRepository) Close() error {
+	var first error
+	for _, stmt := range r.stmts {
+		if err := stmt.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	r.stmts = nil
+	return first
+}
+
+// isStaleStmt reports whether err means the server no longer knows a
+// prepared statement. database/sql re-prepares a *sql.Stmt on each new
+// connection by itself, but the server can still drop a handle on a live
+// one, such as after a proxy reconnects behind our back or a table the
+// statement uses is altered. The statement never ran, so running the query
+// unprepared is safe even for writes.
+func isStaleStmt(err error) bool {
+	var me *mysql.MySQLError
+	if !errors.As(err, &me) {
+		return false
+	}
+	switch me.Number {
+	case 1243, // ER_UNKNOWN_STMT_HANDLER
+		1615: // ER_NEED_REPREPARE
+		return true
+	}
+	return false
+}
+
+// exec runs query through its prepared statement if r has one, falling
+// back to a plain Exec when the statement has gone stale.
+func (r *UserRepository) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if stmt := r.stmts[query]; stmt != nil {
+		res, err := stmt.ExecContext(ctx, args...)
+		if !isStaleStmt(err) {
+			return res, err
+		}
+	}
+	return r.DB.ExecContext(ctx, query, args...)
+}
+
+// query is exec for reads.
+func (r *UserRepository) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if stmt := r.stmts[query]; stmt != nil {
+		rows, err := stmt.QueryContext(ctx, args...)
+		if !isStaleStmt(err) {
+			return rows, err
+		}
+	}
+	return queryRetry(ctx, r.DB, query, args...)
+}
+
+// queryUser is query for a single user. A stale statement only shows up
+// as the Scan error, hence the scan inside.
+func (r *UserRepository) queryUser(ctx context.Context, query string, args ...interface{}) (User, error) {
+	if stmt := r.stmts[query]; stmt != nil {
+		u, err := scanUser(stmt.QueryRowContext(ctx, args...))
+		if !isStaleStmt(err) {
+			return u, err
+		}
+	}
+	return scanUser(r.DB.QueryRowContext(ctx, query, args...))
+}
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestPreparedRepositoryFallsBackOnStaleStatement(t *testing.T) {
+	db, mock := newMock(t)
+	for _, query := range preparedUserQueries {
+		mock.ExpectPrepare(query)
+	}
+	mock.ExpectExec(deleteUserQuery).WithArgs(1).
+		WillReturnError(&mysql.MySQLError{Number: 1243, Message: "Unknown prepared statement handler"})
+	mock.ExpectExec(deleteUserQuery).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	r, err := NewUserRepository(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if err := r.Delete(1); err != nil {
+		t.Fatalf("Delete = %v, want it to rerun unprepared", err)
+	}
+}
+
+func TestNewUserRepositoryPrepareFails(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectPrepare(insertUserQuery).WillBeClosed()
+	mock.ExpectPrepare(userByIDQuery).WillReturnError(errors.New("table users doesn't exist"))
+
+	if _, err := NewUserRepository(context.Background(), db); err == nil {
+		t.Fatal("NewUserRepository succeeded though a prepare failed")
+	}
+}
+
+// roundTrips is a driver that answers everything at once and counts the
+// requests that would each wait on a MySQL server. Like the mysql driver
+// with interpolateParams off, it can't run a statement with arguments
+// without preparing it first.
+type roundTrips struct{ n int64 }
+
+func (rt *roundTrips) Open(string) (driver.Conn, error)             { return rt, nil }
+func (rt *roundTrips) Connect(context.Context) (driver.Conn, error) { return rt, nil }
+func (rt *roundTrips) Driver() driver.Driver                        { return rt }
+func (rt *roundTrips) Close() error                                 { return nil }
+func (rt *roundTrips) Begin() (driver.Tx, error)                    { return nil, errors.New("no transactions") }
+
+func (rt *roundTrips) Prepare(string) (driver.Stmt, error) {
+	atomic.AddInt64(&rt.n, 1)
+	return roundTripStmt{rt}, nil
+}
+
+type roundTripStmt struct{ rt *roundTrips }
+
+// Close doesn't count: the mysql driver sends COM_STMT_CLOSE without
+// waiting for a reply.
+func (s roundTripStmt) Close() error  { return nil }
+func (s roundTripStmt) NumInput() int { return -1 }
+
+func (s roundTripStmt) Exec([]driver.Value) (driver.Result, error) {
+	atomic.AddInt64(&s.rt.n, 1)
+	return driver.RowsAffected(1), nil
+}
+
+func (s roundTripStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not implemented")
+}
+
+// BenchmarkInsertUser runs the insert Create issues, without the bcrypt
+// hash that would otherwise dominate, with and without the statement
+// cache. roundtrips/op is what the cache saves against a real server.
+func BenchmarkInsertUser(b *testing.B) {
+	args, err := insertUserArgs(&User{Username: "alice", Password: "hunter2", Email: "alice@example.com"})
+	if err != nil {
+		b.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, cached := range []bool{false, true} {
+		name := "uncached"
+		if cached {
+			name = "cached"
+		}
+		b.Run(name, func(b *testing.B) {
+			rt := &roundTrips{}
+			db := sql.OpenDB(rt)
+			defer db.Close()
+			r := &UserRepository{DB: db}
+			if cached {
+				if r, err = NewUserRepository(ctx, db); err != nil {
+					b.Fatal(err)
+				}
+				defer r.Close()
+			}
+			atomic.StoreInt64(&rt.n, 0)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := r.exec(ctx, insertUserQuery, args...); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(atomic.LoadInt64(&rt.n))/float64(b.N), "roundtrips/op")
+		})
+	}
+}
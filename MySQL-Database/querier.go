@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Querier is satisfied by *sql.DB, *sql.Conn and *sql.Tx, so the queries in
+// this package run the same way on a pooled handle, a reserved connection
+// or inside a transaction.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// rowIterator is the part of *sql.Rows that collectRows uses.
+type rowIterator interface {
+	rowScanner
+	Next() bool
+	Err() error
+	Close() error
+}
+
+// collectRows scans every row with scan and closes rows. It always checks
+// rows.Err(), which is the only place an error that ends the iteration
+// early (a dropped connection, a cancelled context) shows up; without it
+// such a failure looks like a short result.
+func collectRows[T any](rows rowIterator, scan func(rowScanner) (T, error)) ([]T, error) {
+	defer rows.Close()
+	out := []T{}
+	for rows.Next() {
+		v, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
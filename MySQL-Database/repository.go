@@ -0,0 +1,252 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// UserRepository runs the users table queries against DB.
+//
+// Every method has a Context variant; the plain methods use
+// context.Background() and can block for as long as the server takes.
+// Callers serving requests should bound each call instead:
+//
+//	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+//	defer cancel()
+//	u, err := repo.GetByIDContext(ctx, id)
+//
+// A cancelled or expired context makes the call return an error wrapping
+// context.Canceled or context.DeadlineExceeded.
+//
+// A UserRepository from NewUserRepository reuses prepared statements; one
+// built as a literal runs each query afresh.
+type UserRepository struct {
+	DB *sql.DB
+
+	stmts map[string]*sql.Stmt
+}
+
+// Create inserts u and returns its new id. A taken username is reported as
+// an error wrapping ErrDuplicateUsername.
+func (r *UserRepository) Create(u *User) (int64, error) {
+	return r.CreateContext(context.Background(), u)
+}
+
+// CreateContext is Create with a context.
+func (r *UserRepository) CreateContext(ctx context.Context, u *User) (int64, error) {
+	args, err := insertUserArgs(u)
+	if err != nil {
+		return 0, err
+	}
+	res, err := r.exec(ctx, insertUserQuery, args...)
+	return insertedID(u, res, err)
+}
+
+// CreateWithAudit inserts u and an audit_log row recording action in one
+// transaction, so either both rows are written or neither is.
+func (r *UserRepository) CreateWithAudit(u *User, action string) (int64, error) {
+	return r.CreateWithAuditContext(context.Background(), u, action)
+}
+
+// CreateWithAuditContext is CreateWithAudit with a context.
+func (r *UserRepository) CreateWithAuditContext(ctx context.Context, u *User, action string) (int64, error) {
+	var id int64
+	err := RunInTx(ctx, r.DB, func(tx *Tx) error {
+		var err error
+		if id, err = CreateUser(ctx, tx, u); err != nil {
+			return err
+		}
+		return insertAudit(ctx, tx, u.ID, action, "")
+	})
+	if err != nil {
+		u.ID = 0
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetByID returns the user with the given id, or an error wrapping
+// ErrUserNotFound if there is none.
+func (r *UserRepository) GetByID(id int) (*User, error) {
+	return r.GetByIDContext(context.Background(), id)
+}
+
+// GetByIDContext is GetByID with a context.
+func (r *UserRepository) GetByIDContext(ctx context.Context, id int) (*User, error) {
+	u, err := r.queryUser(ctx, userByIDQuery, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: id %d", ErrUserNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// List returns all users that aren't deleted, ordered by id.
+func (r *UserRepository) List() ([]User, error) {
+	return r.ListContext(context.Background())
+}
+
+// ListContext is List with a context.
+func (r *UserRepository) ListContext(ctx context.Context) ([]User, error) {
+	rows, err := r.query(ctx, listUsersQuery)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows(rows, scanUser)
+}
+
+// ListStream calls fn with each user List would return, in the same
+// order, without holding them all in memory. It stops at the first error
+// from fn, which it returns, and when ctx is done, returning ctx.Err().
+// The connection stays busy until ListStream returns, so fn should not
+// take long per user.
+func (r *UserRepository) ListStream(ctx context.Context, fn func(User) error) error {
+	rows, err := r.query(ctx, listUsersQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		u, err := scanUser(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(u); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ListByUsername returns the users whose username contains pattern,
+// ordered by username. pattern is matched literally: % and _ in it are not
+// wildcards. An empty pattern matches no one.
+func (r *UserRepository) ListByUsername(pattern string) ([]User, error) {
+	return r.ListByUsernameContext(context.Background(), pattern)
+}
+
+// ListByUsernameContext is ListByUsername with a context.
+func (r *UserRepository) ListByUsernameContext(ctx context.Context, pattern string) ([]User, error) {
+	if pattern == "" {
+		return []User{}, nil
+	}
+	rows, err := r.query(ctx, usersByUsernameQuery, "%"+escapeLike(pattern)+"%")
+	if err != nil {
+		return nil, err
+	}
+	return collectRows(rows, scanUser)
+}
+
+// ListPaged returns up to limit users ordered by id, skipping the first
+// offset. A limit outside 1..MaxPageSize is replaced by DefaultPageSize.
+// hasMore reports whether another page follows.
+func (r *UserRepository) ListPaged(limit, offset int) (users []User, hasMore bool, err error) {
+	return r.ListPagedContext(context.Background(), limit, offset)
+}
+
+// ListPagedContext is ListPaged with a context.
+func (r *UserRepository) ListPagedContext(ctx context.Context, limit, offset int) (users []User, hasMore bool, err error) {
+	return ListUsers(ctx, r.DB, limit, offset)
+}
+
+// Update saves the username, email and password of u. The username is
+// normalized first, as on Create. u.Password may hold either the stored
+// hash, which is kept, or a new plaintext password, which is hashed before
+// saving and replaces u.Password. It returns an error wrapping
+// ErrUserNotFound if the user does not exist and one wrapping
+// ErrDuplicateUsername if the new username is taken.
+func (r *UserRepository) Update(u *User) error {
+	return r.UpdateContext(context.Background(), u)
+}
+
+// UpdateContext is Update with a context.
+func (r *UserRepository) UpdateContext(ctx context.Context, u *User) error {
+	u.Username = NormalizeUsername(u.Username)
+	current, err := r.GetByIDContext(ctx, u.ID)
+	if err != nil {
+		return err
+	}
+	if u.Password != current.Password {
+		hash, err := HashPassword(u.Password)
+		if err != nil {
+			return err
+		}
+		u.Password = hash
+	}
+	if u.Username == current.Username && u.Email == current.Email && u.Password == current.Password {
+		return nil
+	}
+
+	// MySQL reports rows changed rather than rows matched, so zero
+	// affected rows means either the user was deleted meanwhile or another
+	// update already saved these same values. Looking again tells which.
+	res, err := r.exec(ctx, updateUserQuery,
+		u.Username, u.Email, u.Password, u.ID)
+	if err != nil {
+		return usernameErr(err, u.Username)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		_, err := r.GetByIDContext(ctx, u.ID)
+		return err
+	}
+	return nil
+}
+
+// Delete soft-deletes the user with the given id by setting deleted_at.
+// The row and its audit history stay; the other methods treat the user as
+// missing until it is restored. Deleting a missing or already deleted
+// user is not an error.
+func (r *UserRepository) Delete(id int) error {
+	return r.DeleteContext(context.Background(), id)
+}
+
+// DeleteContext is Delete with a context.
+func (r *UserRepository) DeleteContext(ctx context.Context, id int) error {
+	_, err := r.exec(ctx, deleteUserQuery, id)
+	return err
+}
+
+// Restore undoes Delete. It returns an error wrapping ErrUserNotFound if
+// the user is missing or not deleted.
+func (r *UserRepository) Restore(id int) error {
+	return r.RestoreContext(context.Background(), id)
+}
+
+// RestoreContext is Restore with a context.
+func (r *UserRepository) RestoreContext(ctx context.Context, id int) error {
+	return RestoreUser(ctx, r.DB, id, "")
+}
+
+// HardDelete removes the user's row for good, whether or not it was
+// soft-deleted. It returns an error wrapping ErrUserNotFound if there is
+// no such row.
+func (r *UserRepository) HardDelete(id int) error {
+	return r.HardDeleteContext(context.Background(), id)
+}
+
+// HardDeleteContext is HardDelete with a context.
+func (r *UserRepository) HardDeleteContext(ctx context.Context, id int) error {
+	res, err := r.exec(ctx, hardDeleteUserQuery, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: id %d", ErrUserNotFound, id)
+	}
+	return nil
+}
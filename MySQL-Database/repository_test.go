@@ -0,0 +1,480 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const (
+	restoreUserQuery = `UPDATE users SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL`
+	insertAuditQuery = `INSERT INTO audit_log (user_id, action, actor) VALUES (?, ?, ?)`
+)
+
+var (
+	alice = User{ID: 1, Username: "alice", Password: "hash-a", Email: "alice@example.com", CreatedAt: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	bob   = User{ID: 2, Username: "bob", Password: "hash-b", Email: "bob@example.com", CreatedAt: time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC)}
+)
+
+func TestSoftDeleteHidesUserUntilRestored(t *testing.T) {
+	db, mock := newMock(t)
+	repo := &UserRepository{DB: db}
+
+	mock.ExpectExec(deleteUserQuery).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(listUsersQuery).WillReturnRows(userRows(alice))
+	mock.ExpectBegin()
+	mock.ExpectExec(restoreUserQuery).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(insertAuditQuery).
+		WithArgs(2, "restore", "").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(listUsersQuery).WillReturnRows(userRows(alice, bob))
+
+	if err := repo.Delete(2); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	users, err := repo.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if !reflect.DeepEqual(users, []User{alice}) {
+		t.Fatalf("List after Delete = %+v, want only alice", users)
+	}
+
+	if err := repo.Restore(2); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	users, err = repo.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if !reflect.DeepEqual(users, []User{alice, bob}) {
+		t.Fatalf("List after Restore = %+v, want alice and bob", users)
+	}
+}
+
+func TestRestoreNotDeleted(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(restoreUserQuery).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err := (&UserRepository{DB: db}).Restore(1)
+	if !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("Restore error = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestHardDelete(t *testing.T) {
+	db, mock := newMock(t)
+	repo := &UserRepository{DB: db}
+	mock.ExpectExec(hardDeleteUserQuery).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(hardDeleteUserQuery).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := repo.HardDelete(2); err != nil {
+		t.Fatalf("HardDelete: %v", err)
+	}
+	if err := repo.HardDelete(2); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("second HardDelete error = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestUpdateDeletedMeanwhile(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectQuery(userByIDQuery).WithArgs(1).WillReturnRows(userRows(alice))
+	mock.ExpectExec(updateUserQuery).
+		WithArgs("alice", "new@example.com", "hash-a", 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(userByIDQuery).WithArgs(1).WillReturnRows(userRows())
+
+	u := alice
+	u.Email = "new@example.com"
+	err := (&UserRepository{DB: db}).Update(&u)
+	if !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("Update error = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestUpdateAlreadySaved(t *testing.T) {
+	db, mock := newMock(t)
+	saved := alice
+	saved.Email = "new@example.com"
+	mock.ExpectQuery(userByIDQuery).WithArgs(1).WillReturnRows(userRows(alice))
+	mock.ExpectExec(updateUserQuery).
+		WithArgs("alice", "new@example.com", "hash-a", 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(userByIDQuery).WithArgs(1).WillReturnRows(userRows(saved))
+
+	u := saved
+	if err := (&UserRepository{DB: db}).Update(&u); err != nil {
+		t.Fatalf("Update = %v, want nil when another update saved the same values", err)
+	}
+}
+
+func TestUpdateNormalizesUsername(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectQuery(userByIDQuery).WithArgs(1).WillReturnRows(userRows(alice))
+	mock.ExpectExec(updateUserQuery).
+		WithArgs("alicia", "alice@example.com", "hash-a", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	u := alice
+	u.Username = "  Alicia "
+	if err := (&UserRepository{DB: db}).Update(&u); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if u.Username != "alicia" {
+		t.Errorf("u.Username = %q, want alicia", u.Username)
+	}
+}
+
+func TestUpdateUnchangedAfterNormalizing(t *testing.T) {
+	db, mock := newMock(t)
+	// Only the lookup: " ALICE" is alice's stored name, so nothing changes.
+	mock.ExpectQuery(userByIDQuery).WithArgs(1).WillReturnRows(userRows(alice))
+
+	u := alice
+	u.Username = " ALICE"
+	if err := (&UserRepository{DB: db}).Update(&u); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+}
+
+func TestUserRepositoryCRUD(t *testing.T) {
+	tests := []struct {
+		name   string
+		expect func(sqlmock.Sqlmock)
+		run    func(*UserRepository) error
+	}{
+		{
+			name: "create",
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectExec(insertUserQuery).
+					WithArgs("carol", sqlmock.AnyArg(), "carol@example.com", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(3, 1))
+			},
+			run: func(r *UserRepository) error {
+				u := User{Username: "Carol", Password: "pw", Email: "carol@example.com"}
+				id, err := r.Create(&u)
+				if err == nil && (id != 3 || u.ID != 3) {
+					return fmt.Errorf("Create id = %d, u.ID = %d, want 3", id, u.ID)
+				}
+				return err
+			},
+		},
+		{
+			name: "get",
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(userByIDQuery).WithArgs(1).WillReturnRows(userRows(alice))
+			},
+			run: func(r *UserRepository) error {
+				u, err := r.GetByID(1)
+				if err == nil && *u != alice {
+					return fmt.Errorf("GetByID = %+v, want alice", *u)
+				}
+				return err
+			},
+		},
+		{
+			name: "list",
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(listUsersQuery).WillReturnRows(userRows(alice, bob))
+			},
+			run: func(r *UserRepository) error {
+				users, err := r.List()
+				if err == nil && !reflect.DeepEqual(users, []User{alice, bob}) {
+					return fmt.Errorf("List = %+v, want alice and bob", users)
+				}
+				return err
+			},
+		},
+		{
+			name: "update",
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(userByIDQuery).WithArgs(2).WillReturnRows(userRows(bob))
+				m.ExpectExec(updateUserQuery).WithArgs("robert", "bob@example.com", "hash-b", 2).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			run: func(r *UserRepository) error {
+				u := bob
+				u.Username = "robert"
+				return r.Update(&u)
+			},
+		},
+		{
+			name: "delete",
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectExec(deleteUserQuery).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			run: func(r *UserRepository) error { return r.Delete(2) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMock(t)
+			tt.expect(mock)
+			if err := tt.run(&UserRepository{DB: db}); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestContextMethodsHonourCancel(t *testing.T) {
+	db, _ := newMock(t)
+	repo := &UserRepository{DB: db}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := repo.GetByIDContext(ctx, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetByIDContext error = %v, want context.Canceled", err)
+	}
+	u := User{Username: "carol", Password: "pw"}
+	if _, err := repo.CreateContext(ctx, &u); !errors.Is(err, context.Canceled) {
+		t.Errorf("CreateContext error = %v, want context.Canceled", err)
+	}
+	if _, err := repo.ListContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("ListContext error = %v, want context.Canceled", err)
+	}
+}
+
+func TestGetByIDMissing(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectQuery(userByIDQuery).WithArgs(42).WillReturnRows(userRows())
+
+	u, err := (&UserRepository{DB: db}).GetByID(42)
+	if !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("GetByID error = %v, want ErrUserNotFound", err)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetByID error %v still exposes sql.ErrNoRows", err)
+	}
+	if u != nil {
+		t.Errorf("GetByID user = %+v, want nil", u)
+	}
+}
+
+func TestGetByIDPassesOtherErrors(t *testing.T) {
+	db, mock := newMock(t)
+	boom := errors.New("connection reset")
+	mock.ExpectQuery(userByIDQuery).WithArgs(1).WillReturnError(boom)
+
+	_, err := (&UserRepository{DB: db}).GetByID(1)
+	if !errors.Is(err, boom) || errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("GetByID error = %v, want the driver error unchanged", err)
+	}
+}
+
+func TestUpdateRehashesChangedPassword(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectQuery(userByIDQuery).WithArgs(1).WillReturnRows(userRows(alice))
+	mock.ExpectExec(updateUserQuery).
+		WithArgs("alice", "alice@example.com", sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	u := alice
+	u.Password = "correct horse"
+	if err := (&UserRepository{DB: db}).Update(&u); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if u.Password == "correct horse" {
+		t.Fatal("Update stored the plain password")
+	}
+	if err := CheckPassword(u.Password, "correct horse"); err != nil {
+		t.Errorf("stored hash doesn't match the new password: %v", err)
+	}
+}
+
+func TestUpdateNoSuchUser(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectQuery(userByIDQuery).WithArgs(9).WillReturnRows(userRows())
+
+	u := User{ID: 9, Username: "ghost", Password: "pw"}
+	if err := (&UserRepository{DB: db}).Update(&u); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("Update error = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestListPaged(t *testing.T) {
+	const pageQuery = `SELECT ` + userColumns + ` FROM users WHERE deleted_at IS NULL ORDER BY id LIMIT ? OFFSET ?`
+	var all []User
+	for i := 1; i <= 5; i++ {
+		all = append(all, User{ID: i, Username: fmt.Sprintf("user%d", i), CreatedAt: alice.CreatedAt})
+	}
+
+	tests := []struct {
+		name          string
+		limit, offset int
+		queryLimit    int
+		rows          []User
+		want          []User
+		wantMore      bool
+	}{
+		{"first", 2, 0, 3, all[0:3], all[0:2], true},
+		{"middle", 2, 2, 3, all[2:5], all[2:4], true},
+		{"last", 2, 4, 3, all[4:5], all[4:5], false},
+		{"limit out of range", 500, 0, DefaultPageSize + 1, all, all, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMock(t)
+			mock.ExpectQuery(pageQuery).WithArgs(tt.queryLimit, tt.offset).WillReturnRows(userRows(tt.rows...))
+
+			users, hasMore, err := (&UserRepository{DB: db}).ListPaged(tt.limit, tt.offset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(users, tt.want) || hasMore != tt.wantMore {
+				t.Errorf("ListPaged = %+v, %v; want %+v, %v", users, hasMore, tt.want, tt.wantMore)
+			}
+		})
+	}
+}
+
+func TestCreateWithAuditRollsBackUser(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(insertUserQuery).
+		WithArgs("carol", sqlmock.AnyArg(), "carol@example.com", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectExec(insertAuditQuery).WithArgs(3, "signup", "").WillReturnError(errors.New("audit_log is full"))
+	mock.ExpectRollback()
+
+	u := User{Username: "carol", Password: "pw", Email: "carol@example.com"}
+	id, err := (&UserRepository{DB: db}).CreateWithAudit(&u, "signup")
+	if err == nil {
+		t.Fatal("CreateWithAudit succeeded with a failing audit insert")
+	}
+	if id != 0 || u.ID != 0 {
+		t.Errorf("id = %d, u.ID = %d after rollback, want 0", id, u.ID)
+	}
+}
+
+func TestCreateWithAuditCommitsBoth(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(insertUserQuery).
+		WithArgs("carol", sqlmock.AnyArg(), "carol@example.com", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectExec(insertAuditQuery).WithArgs(3, "signup", "").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	u := User{Username: "carol", Password: "pw", Email: "carol@example.com"}
+	id, err := (&UserRepository{DB: db}).CreateWithAudit(&u, "signup")
+	if err != nil || id != 3 {
+		t.Fatalf("CreateWithAudit = %d, %v; want 3, nil", id, err)
+	}
+}
+
+func TestListSurfacesMidIterationError(t *testing.T) {
+	db, mock := newMock(t)
+	dropped := errors.New("connection reset mid-result")
+	mock.ExpectQuery(listUsersQuery).
+		WillReturnRows(userRows(alice, bob).RowError(1, dropped)).
+		RowsWillBeClosed()
+
+	users, err := (&UserRepository{DB: db}).List()
+	if !errors.Is(err, dropped) || users != nil {
+		t.Fatalf("List = %v, %v; want no users and the rows error", users, err)
+	}
+}
+
+func TestCollectRowsClosesOnScanError(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectQuery(listUsersQuery).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password", "email", "created_at"}).
+			AddRow("not-a-number", "alice", "", "", time.Now())).
+		RowsWillBeClosed()
+
+	rows, err := db.Query(listUsersQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if users, err := collectRows(rows, scanUser); err == nil {
+		t.Fatalf("collectRows = %v, want the scan error", users)
+	}
+}
+
+func TestListByUsername(t *testing.T) {
+	tests := []struct {
+		name, pattern, arg string
+	}{
+		{"literal", "alice", "%alice%"},
+		{"partial", "li", "%li%"},
+		{"literal underscore", "a_b", "%a!_b%"},
+		{"literal percent", "%", "%!%%"},
+		{"escape character", "a!b", "%a!!b%"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMock(t)
+			mock.ExpectQuery(usersByUsernameQuery).WithArgs(tt.arg).WillReturnRows(userRows(alice))
+
+			users, err := (&UserRepository{DB: db}).ListByUsername(tt.pattern)
+			if err != nil || len(users) != 1 || users[0].Username != alice.Username {
+				t.Errorf("ListByUsername(%q) = %v, %v; want alice", tt.pattern, users, err)
+			}
+		})
+	}
+}
+
+func TestListByUsernameEmptyPattern(t *testing.T) {
+	db, _ := newMock(t)
+	users, err := (&UserRepository{DB: db}).ListByUsername("")
+	if err != nil || len(users) != 0 {
+		t.Errorf("ListByUsername(\"\") = %v, %v; want no users and no query", users, err)
+	}
+}
+
+func TestListStreamStopsOnCancel(t *testing.T) {
+	db, mock := newMock(t)
+	carol := User{ID: 3, Username: "carol", CreatedAt: alice.CreatedAt}
+	mock.ExpectQuery(listUsersQuery).WillReturnRows(userRows(alice, bob, carol, alice, bob)).RowsWillBeClosed()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var seen []string
+	err := (&UserRepository{DB: db}).ListStream(ctx, func(u User) error {
+		seen = append(seen, u.Username)
+		if len(seen) == 2 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("ListStream = %v, want context.Canceled", err)
+	}
+	if want := []string{"alice", "bob"}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("callback saw %v, want it to stop after %v", seen, want)
+	}
+}
+
+func TestListStream(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectQuery(listUsersQuery).WillReturnRows(userRows(alice, bob))
+	stop := errors.New("stop")
+	mock.ExpectQuery(listUsersQuery).WillReturnRows(userRows(alice, bob)).RowsWillBeClosed()
+	repo := &UserRepository{DB: db}
+
+	var seen []User
+	if err := repo.ListStream(context.Background(), func(u User) error {
+		seen = append(seen, u)
+		return nil
+	}); err != nil || !reflect.DeepEqual(seen, []User{alice, bob}) {
+		t.Errorf("ListStream = %v after %v, want alice and bob", err, seen)
+	}
+
+	calls := 0
+	err := repo.ListStream(context.Background(), func(User) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("ListStream = %v after %d calls, want the callback's error after 1", err, calls)
+	}
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// DefaultConnMaxIdleTime is how long a connection may sit unused in the
+// pool before it is closed.
+//
+// MySQL drops connections that have been idle for wait_timeout seconds
+// (8 hours by default, often far less on managed servers and behind
+// proxies). The pool does not notice, and the next query on such a
+// connection fails with "invalid connection" or a packet sequence error
+// (ErrPktSync, logged by the driver as "packets out of order"). Keeping the
+// idle time well below wait_timeout means the pool retires connections
+// before the server does; queryRetry covers whatever slips through.
+const DefaultConnMaxIdleTime = 5 * time.Minute
+
+// isStaleConn reports whether err means the connection was cut by the
+// server while idle, as opposed to the query itself failing.
+func isStaleConn(err error) bool {
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, mysql.ErrPktSync) ||
+		errors.Is(err, mysql.ErrPktSyncMul)
+}
+
+// queryRetry runs a read query, retrying it once when it failed on a
+// connection the server closed while idle. The driver marks such a
+// connection invalid, so database/sql discards it and the retry runs on a
+// fresh one. This only helps on a pooled *sql.DB: a dedicated *sql.Conn or
+// *sql.Tx cannot be retried onto another connection.
+//
+// Only use it for reads; a write may have reached the server before the
+// connection broke.
+func queryRetry(ctx context.Context, db Querier, query string, args ...interface{}) (*sql.Rows, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil && isStaleConn(err) && ctx.Err() == nil {
+		rows, err = db.QueryContext(ctx, query, args...)
+	}
+	return rows, err
+}
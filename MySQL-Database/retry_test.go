@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestQueryRetryAfterIdleError(t *testing.T) {
+	for _, idleErr := range []error{mysql.ErrPktSync, mysql.ErrInvalidConn} {
+		t.Run(idleErr.Error(), func(t *testing.T) {
+			db, mock := newMock(t)
+			mock.ExpectQuery(listUsersQuery).WillReturnError(idleErr)
+			mock.ExpectQuery(listUsersQuery).WillReturnRows(userRows(alice))
+
+			rows, err := queryRetry(context.Background(), db, listUsersQuery)
+			if err != nil {
+				t.Fatalf("queryRetry: %v", err)
+			}
+			users, err := collectRows(rows, scanUser)
+			if err != nil || len(users) != 1 {
+				t.Fatalf("got %v, %v; want alice from the retry", users, err)
+			}
+		})
+	}
+}
+
+func TestQueryRetryLeavesOtherErrors(t *testing.T) {
+	db, mock := newMock(t)
+	syntax := &mysql.MySQLError{Number: 1064, Message: "syntax error"}
+	mock.ExpectQuery(listUsersQuery).WillReturnError(syntax)
+
+	if _, err := queryRetry(context.Background(), db, listUsersQuery); !errors.Is(err, syntax) {
+		t.Fatalf("queryRetry error = %v, want the syntax error without a retry", err)
+	}
+}
+
+func TestQueryRetryRetriesOnce(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectQuery(listUsersQuery).WillReturnError(mysql.ErrPktSync)
+	mock.ExpectQuery(listUsersQuery).WillReturnError(mysql.ErrPktSync)
+
+	if _, err := queryRetry(context.Background(), db, listUsersQuery); !errors.Is(err, mysql.ErrPktSync) {
+		t.Fatalf("queryRetry error = %v, want ErrPktSync after one retry", err)
+	}
+}
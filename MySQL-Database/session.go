@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// MySQLSessionStore keeps login sessions in the sessions table, so any
+// instance can check them and deleting the row revokes one at once. The
+// table holds a SHA-256 of each token rather than the token, so reading it
+// doesn't let anyone take over a session.
+type MySQLSessionStore struct {
+	DB Querier
+	// TTL is how long a session lasts from its creation.
+	TTL time.Duration
+}
+
+// querier returns the connection Backpressure reserved for the request
+// behind ctx, so a request holding the last pooled connection doesn't wait
+// on a second one for its session, and s.DB otherwise.
+func (s *MySQLSessionStore) querier(ctx context.Context) Querier {
+	if conn := ConnFromContext(ctx); conn != nil {
+		return conn
+	}
+	return s.DB
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create starts a session for userID and returns its token and expiry.
+func (s *MySQLSessionStore) Create(ctx context.Context, userID int) (string, time.Time, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	// DATETIME keeps whole seconds.
+	expires := time.Now().Add(s.TTL).Truncate(time.Second)
+	if _, err := s.querier(ctx).ExecContext(ctx, `INSERT INTO sessions (token_hash, user_id, expires_at) VALUES (?, ?, ?)`,
+		hashToken(token), userID, expires); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expires, nil
+}
+
+// Get returns the user of the session with the given token. ok is false if
+// there is no such session or it has expired.
+func (s *MySQLSessionStore) Get(ctx context.Context, token string) (userID int, ok bool, err error) {
+	err = s.querier(ctx).QueryRowContext(ctx, `SELECT user_id FROM sessions WHERE token_hash = ? AND expires_at > ?`,
+		hashToken(token), time.Now()).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return userID, true, nil
+}
+
+// Delete ends the session with the given token. Deleting an unknown token
+// is not an error.
+func (s *MySQLSessionStore) Delete(ctx context.Context, token string) error {
+	_, err := s.querier(ctx).ExecContext(ctx, `DELETE FROM sessions WHERE token_hash = ?`, hashToken(token))
+	return err
+}
+
+// DeleteExpired removes expired sessions and returns how many there were.
+// Get ignores them anyway; this only keeps the table small.
+func (s *MySQLSessionStore) DeleteExpired(ctx context.Context) (int64, error) {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM sessions WHERE expires_at <= ?`, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
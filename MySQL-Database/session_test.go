@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const (
+	insertSessionQuery = `INSERT INTO sessions (token_hash, user_id, expires_at) VALUES (?, ?, ?)`
+	getSessionQuery    = `SELECT user_id FROM sessions WHERE token_hash = ? AND expires_at > ?`
+	deleteSessionQuery = `DELETE FROM sessions WHERE token_hash = ?`
+)
+
+// capture is a sqlmock argument that matches anything and keeps it.
+type capture struct{ v driver.Value }
+
+func (c *capture) Match(v driver.Value) bool {
+	c.v = v
+	return true
+}
+
+// aroundNow matches a time within a second of the current one.
+type aroundNow struct{}
+
+func (aroundNow) Match(v driver.Value) bool {
+	t, ok := v.(time.Time)
+	return ok && time.Since(t).Abs() < time.Second
+}
+
+func TestMySQLSessionStoreCreate(t *testing.T) {
+	db, mock := newMock(t)
+	var hash, expires capture
+	mock.ExpectExec(insertSessionQuery).WithArgs(&hash, 7, &expires).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	store := &MySQLSessionStore{DB: db, TTL: time.Hour}
+	token, exp, err := store.Create(context.Background(), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token == "" || hash.v != hashToken(token) {
+		t.Errorf("stored %v for token %q, want its SHA-256 and not the token", hash.v, token)
+	}
+	if until := time.Until(exp); until < 59*time.Minute || until > time.Hour || !expires.v.(time.Time).Equal(exp) {
+		t.Errorf("expires %v (stored %v), want an hour from now", exp, expires.v)
+	}
+}
+
+func TestMySQLSessionStoreGet(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectQuery(getSessionQuery).WithArgs(hashToken("valid"), aroundNow{}).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(7))
+	// The expiry is checked by the query, so an expired session is not
+	// found.
+	mock.ExpectQuery(getSessionQuery).WithArgs(hashToken("expired"), aroundNow{}).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+
+	store := &MySQLSessionStore{DB: db, TTL: time.Hour}
+	if id, ok, err := store.Get(context.Background(), "valid"); err != nil || !ok || id != 7 {
+		t.Errorf("Get(valid) = %d, %v, %v; want user 7", id, ok, err)
+	}
+	if id, ok, err := store.Get(context.Background(), "expired"); err != nil || ok {
+		t.Errorf("Get(expired) = %d, %v, %v; want not ok and no error", id, ok, err)
+	}
+}
+
+func TestMySQLSessionStoreDelete(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectExec(deleteSessionQuery).WithArgs(hashToken("tok")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := (&MySQLSessionStore{DB: db}).Delete(context.Background(), "tok"); err != nil {
+		t.Errorf("Delete of an unknown token = %v, want nil", err)
+	}
+}
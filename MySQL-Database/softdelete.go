@@ -0,0 +1,27 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// RestoreUser clears deleted_at on a soft-deleted user and records in the
+// audit log that actor restored it, in one transaction. It returns an
+// error wrapping ErrUserNotFound if id is missing or not deleted.
+func RestoreUser(ctx context.Context, db *sql.DB, id int, actor string) error {
+	return RunInTx(ctx, db, func(tx *Tx) error {
+		res, err := tx.ExecContext(ctx, `UPDATE users SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL`, id)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("%w: no deleted user with id %d", ErrUserNotFound, id)
+		}
+		return insertAudit(ctx, tx, id, "restore", actor)
+	})
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRestoreUserWritesAudit(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(restoreUserQuery).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(insertAuditQuery).WithArgs(2, "restore", "admin@example.com").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := RestoreUser(context.Background(), db, 2, "admin@example.com"); err != nil {
+		t.Fatalf("RestoreUser: %v", err)
+	}
+}
+
+func TestRestoreUserNotDeletedOrMissing(t *testing.T) {
+	// The UPDATE matches neither a live user nor a missing id, so both
+	// look the same: no row, no audit entry, rolled back.
+	for _, id := range []int{1, 99} {
+		db, mock := newMock(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(restoreUserQuery).WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		if err := RestoreUser(context.Background(), db, id, "admin@example.com"); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("RestoreUser(%d) error = %v, want ErrUserNotFound", id, err)
+		}
+	}
+}
+
+func TestRestoreUserAuditFailureRollsBack(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(restoreUserQuery).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(insertAuditQuery).WithArgs(2, "restore", "admin@example.com").WillReturnError(errors.New("disk full"))
+	mock.ExpectRollback()
+
+	if err := RestoreUser(context.Background(), db, 2, "admin@example.com"); err == nil {
+		t.Fatal("RestoreUser succeeded without its audit row")
+	}
+}
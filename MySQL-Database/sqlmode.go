@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// DefaultSQLMode makes MySQL reject out-of-range and truncated values
+// instead of storing an adjusted value with only a warning.
+const DefaultSQLMode = "STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_ENGINE_SUBSTITUTION"
+
+// sqlModePattern matches a comma-separated list of mode names. Nothing else
+// can reach the SET statement, which has to be sent without placeholders.
+var sqlModePattern = regexp.MustCompile(`^[A-Z_]+(,[A-Z_]+)*$`)
+
+// sqlModeConnector runs SET SESSION sql_mode on every connection it makes.
+type sqlModeConnector struct {
+	driver.Connector
+	stmt string
+}
+
+// WithSQLMode wraps c so that each new connection starts with
+// SET SESSION sql_mode set to mode. The statement is sent without
+// arguments: the driver answers driver.ErrSkip for a parameterised Exec
+// on a raw connection unless interpolateParams is on, so mode is validated
+// and inlined instead.
+func WithSQLMode(c driver.Connector, mode string) (driver.Connector, error) {
+	if !sqlModePattern.MatchString(mode) {
+		return nil, fmt.Errorf("database: invalid sql_mode %q", mode)
+	}
+	return &sqlModeConnector{Connector: c, stmt: "SET SESSION sql_mode = '" + mode + "'"}, nil
+}
+
+func (c *sqlModeConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		conn.Close()
+		return nil, errors.New("database: driver connection cannot execute statements")
+	}
+	if _, err := execer.ExecContext(ctx, c.stmt, nil); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("set sql_mode: %w", err)
+	}
+	return conn, nil
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// mockConnector opens sqlmock connections by DSN, standing in for the
+// mysql connector main wraps.
+type mockConnector struct {
+	drv driver.Driver
+	dsn string
+}
+
+func (c mockConnector) Connect(context.Context) (driver.Conn, error) { return c.drv.Open(c.dsn) }
+func (c mockConnector) Driver() driver.Driver                        { return c.drv }
+
+// newMockConnector returns a connector for a fresh sqlmock; opening a
+// connection through it meets the mock's expectations.
+func newMockConnector(t *testing.T, dsn string) (driver.Connector, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.NewWithDSN(dsn, sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return mockConnector{drv: db.Driver(), dsn: dsn}, mock
+}
+
+func TestWithSQLModeRunsOnConnect(t *testing.T) {
+	connector, mock := newMockConnector(t, "sqlmode-connect")
+	mock.ExpectExec("SET SESSION sql_mode = 'STRICT_TRANS_TABLES,NO_ZERO_DATE'").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+
+	c, err := WithSQLMode(connector, "STRICT_TRANS_TABLES,NO_ZERO_DATE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(c)
+	defer db.Close()
+
+	var one int
+	if err := db.QueryRow("SELECT 1").Scan(&one); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWithSQLModeFailureClosesConnection(t *testing.T) {
+	connector, mock := newMockConnector(t, "sqlmode-fail")
+	mock.ExpectExec("SET SESSION sql_mode = 'STRICT_TRANS_TABLES'").
+		WillReturnError(errors.New("Variable 'sql_mode' can't be set"))
+	mock.ExpectClose()
+
+	c, err := WithSQLMode(connector, "STRICT_TRANS_TABLES")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(c)
+	defer db.Close()
+
+	if err := db.Ping(); err == nil {
+		t.Fatal("Ping succeeded though setting sql_mode failed")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWithSQLModeRejectsInjection(t *testing.T) {
+	for _, mode := range []string{"", "strict_trans_tables", "STRICT_TRANS_TABLES'; DROP TABLE users; --", "A,,B"} {
+		if _, err := WithSQLMode(mockConnector{}, mode); err == nil {
+			t.Errorf("WithSQLMode(%q) succeeded", mode)
+		}
+	}
+}
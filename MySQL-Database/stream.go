@@ -0,0 +1,46 @@
+package database
+
+import "context"
+
+// StreamUsers queries all users that aren't deleted, ordered by id, and
+// sends them on the returned channel, which is closed when the rows run
+// out, a scan fails or ctx is done. At most one error is sent on the error
+// channel, which is closed after the user channel; ctx.Err() is sent when
+// the stream stopped early because of ctx.
+//
+// The rows stay open until the stream ends, holding a connection, so the
+// caller must either drain the user channel or cancel ctx.
+func StreamUsers(ctx context.Context, db Querier) (<-chan User, <-chan error) {
+	users := make(chan User)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(errc)
+		defer close(users)
+
+		rows, err := queryRetry(ctx, db, `SELECT `+userColumns+` FROM users WHERE deleted_at IS NULL ORDER BY id`)
+		if err != nil {
+			errc <- err
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			u, err := scanUser(rows)
+			if err != nil {
+				errc <- err
+				return
+			}
+			select {
+			case users <- u:
+			case <-ctx.Done():
+				errc <- ctx.Err()
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			errc <- err
+		}
+	}()
+	return users, errc
+}
@@ -0,0 +1,47 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const streamUsersQuery = `SELECT ` + userColumns + ` FROM users WHERE deleted_at IS NULL ORDER BY id`
+
+func TestStreamUsersSendsAll(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectQuery(streamUsersQuery).WillReturnRows(userRows(alice, bob)).RowsWillBeClosed()
+
+	users, errc := StreamUsers(context.Background(), db)
+	var got []User
+	for u := range users {
+		got = append(got, u)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("stream error: %v", err)
+	}
+	if !reflect.DeepEqual(got, []User{alice, bob}) {
+		t.Errorf("streamed %+v, want alice and bob", got)
+	}
+}
+
+func TestStreamUsersStopsOnCancel(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectQuery(streamUsersQuery).WillReturnRows(userRows(alice, bob)).RowsWillBeClosed()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	users, errc := StreamUsers(ctx, db)
+	if u := <-users; u.ID != alice.ID {
+		t.Fatalf("first user = %+v, want alice", u)
+	}
+	cancel()
+
+	// Nobody takes bob, so the stream can only end through ctx.
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Fatalf("stream error = %v, want context.Canceled", err)
+	}
+	if _, open := <-users; open {
+		t.Error("user channel still open after the stream ended")
+	}
+}
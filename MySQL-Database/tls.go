@@ -0,0 +1,45 @@
+package database
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// TLSConfigName is the name the CA-verified TLS config is registered
+// under. A DSN opts into it with the parameter tls=custom.
+const TLSConfigName = "custom"
+
+// RegisterTLS loads the PEM encoded CA bundle at caFile and registers a TLS
+// config that verifies the server certificate against it. The driver
+// checks the certificate against the host in the DSN.
+func RegisterTLS(caFile string) error {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return fmt.Errorf("read MySQL CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return errors.New("read MySQL CA: no certificates found in " + caFile)
+	}
+
+	return mysql.RegisterTLSConfig(TLSConfigName, &tls.Config{
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+	})
+}
+
+// DSNWithTLS returns dsn with tls=custom set, so connections made from it
+// use the config installed by RegisterTLS.
+func DSNWithTLS(dsn string) (string, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", err
+	}
+	cfg.TLSConfig = TLSConfigName
+	return cfg.FormatDSN(), nil
+}
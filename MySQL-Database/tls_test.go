@@ -0,0 +1,85 @@
+//go:build mysqltls
+
+// The TLS tests register a config in the driver's global registry, so they
+// only run with -tags mysqltls.
+
+package database
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// writeCA writes a self-signed CA certificate to a file and returns its path.
+func writeCA(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test MySQL CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRegisterTLS(t *testing.T) {
+	if err := RegisterTLS(writeCA(t)); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { mysql.DeregisterTLSConfig(TLSConfigName) })
+
+	dsn, err := DSNWithTLS(DefaultDSN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(dsn, "tls="+TLSConfigName) {
+		t.Errorf("DSN %q lacks tls=%s", dsn, TLSConfigName)
+	}
+
+	// ParseDSN only accepts the name while a config is registered under it.
+	if _, err := mysql.ParseDSN(dsn); err != nil {
+		t.Fatalf("ParseDSN(%q) after RegisterTLS: %v", dsn, err)
+	}
+	mysql.DeregisterTLSConfig(TLSConfigName)
+	if _, err := mysql.ParseDSN(dsn); err == nil {
+		t.Fatalf("ParseDSN(%q) accepted tls=%s with nothing registered", dsn, TLSConfigName)
+	}
+}
+
+func TestRegisterTLSRejectsNonPEM(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterTLS(path); err == nil {
+		mysql.DeregisterTLSConfig(TLSConfigName)
+		t.Fatal("RegisterTLS accepted a file without certificates")
+	}
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+)
+
+// Tx is a transaction with MySQL savepoint support. It embeds *sql.Tx, so
+// it can be passed wherever a Querier is expected.
+type Tx struct {
+	*sql.Tx
+}
+
+// Savepoint names are spliced into the statement, so they are restricted
+// to plain identifiers.
+var savepointName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,63}$`)
+
+func (tx *Tx) savepointExec(ctx context.Context, stmt, name string) error {
+	if !savepointName.MatchString(name) {
+		return fmt.Errorf("database: invalid savepoint name %q", name)
+	}
+	_, err := tx.ExecContext(ctx, stmt+" "+name)
+	return err
+}
+
+// Savepoint marks a point inside the transaction that RollbackTo can
+// return to.
+func (tx *Tx) Savepoint(ctx context.Context, name string) error {
+	return tx.savepointExec(ctx, "SAVEPOINT", name)
+}
+
+// RollbackTo undoes everything done since Savepoint(name) without ending
+// the transaction.
+func (tx *Tx) RollbackTo(ctx context.Context, name string) error {
+	return tx.savepointExec(ctx, "ROLLBACK TO SAVEPOINT", name)
+}
+
+// Release forgets the savepoint, keeping the work done since it was set.
+func (tx *Tx) Release(ctx context.Context, name string) error {
+	return tx.savepointExec(ctx, "RELEASE SAVEPOINT", name)
+}
+
+// Nested runs fn inside savepoint name. If fn fails its changes are rolled
+// back to the savepoint and fn's error is returned, while the enclosing
+// transaction stays usable and can still commit.
+func (tx *Tx) Nested(ctx context.Context, name string, fn func(*Tx) error) error {
+	if err := tx.Savepoint(ctx, name); err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		if rbErr := tx.RollbackTo(ctx, name); rbErr != nil {
+			return fmt.Errorf("%w (rollback to savepoint %s: %v)", err, name, rbErr)
+		}
+		return err
+	}
+	return tx.Release(ctx, name)
+}
+
+// TxBeginner starts transactions. *sql.DB and *sql.Conn both satisfy it.
+type TxBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// RunInTx runs fn in a transaction, committing if it returns nil and
+// rolling back if it returns an error or panics.
+func RunInTx(ctx context.Context, db TxBeginner, fn func(*Tx) error) (err error) {
+	sqlTx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	tx := &Tx{sqlTx}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("%w (rollback: %v)", err, rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
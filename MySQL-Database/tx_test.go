@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestNestedRollsBackToSavepointAndCommits(t *testing.T) {
+	db, mock := newMock(t)
+	ctx := context.Background()
+	inner := errors.New("inner failed")
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO audit_log (user_id, action, actor) VALUES (?, ?, ?)`).
+		WithArgs(1, "outer", "").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`SAVEPOINT step`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO audit_log (user_id, action, actor) VALUES (?, ?, ?)`).
+		WithArgs(1, "inner", "").WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec(`ROLLBACK TO SAVEPOINT step`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	err := RunInTx(ctx, db, func(tx *Tx) error {
+		if err := insertAudit(ctx, tx, 1, "outer", ""); err != nil {
+			return err
+		}
+		err := tx.Nested(ctx, "step", func(tx *Tx) error {
+			if err := insertAudit(ctx, tx, 1, "inner", ""); err != nil {
+				return err
+			}
+			return inner
+		})
+		if !errors.Is(err, inner) {
+			t.Errorf("Nested error = %v, want the inner error", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("RunInTx: %v", err)
+	}
+}
+
+func TestNestedReleasesOnSuccess(t *testing.T) {
+	db, mock := newMock(t)
+	ctx := context.Background()
+	mock.ExpectBegin()
+	mock.ExpectExec(`SAVEPOINT step`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`RELEASE SAVEPOINT step`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	err := RunInTx(ctx, db, func(tx *Tx) error {
+		return tx.Nested(ctx, "step", func(*Tx) error { return nil })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSavepointRejectsBadName(t *testing.T) {
+	db, mock := newMock(t)
+	ctx := context.Background()
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	err := RunInTx(ctx, db, func(tx *Tx) error {
+		return tx.Savepoint(ctx, "x; DROP TABLE users")
+	})
+	if err == nil {
+		t.Fatal("Savepoint accepted a name that isn't an identifier")
+	}
+}
@@ -0,0 +1,374 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// ErrUserNotFound is returned when no user has the requested id.
+var ErrUserNotFound = errors.New("database: user not found")
+
+// ErrDuplicateUsername is returned when a write would give two users the
+// same username.
+var ErrDuplicateUsername = errors.New("database: username already taken")
+
+// isDuplicateKey reports whether err is MySQL's ER_DUP_ENTRY. Besides the
+// auto-increment id, users_username is the only unique key a user write
+// can collide on, so for those writes it means a duplicate username.
+// Soft-deleted users keep their username and still hold the key.
+func isDuplicateKey(err error) bool {
+	var me *mysql.MySQLError
+	return errors.As(err, &me) && me.Number == 1062
+}
+
+// usernameErr translates a duplicate-key error from a write of u.
+func usernameErr(err error, username string) error {
+	if isDuplicateKey(err) {
+		return fmt.Errorf("%w: %q", ErrDuplicateUsername, username)
+	}
+	return err
+}
+
+// User is a row of the users table. Rows with deleted_at set are
+// soft-deleted users; the functions and methods reading users skip them
+// unless documented otherwise.
+type User struct {
+	ID        int       `json:"id"`
+	Username  string    `json:"username"`
+	Password  string    `json:"-"`
+	Email     string    `json:"email,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const userColumns = "id, username, password, email, created_at"
+
+func scanUser(s rowScanner) (User, error) {
+	var u User
+	err := s.Scan(&u.ID, &u.Username, &u.Password, &u.Email, &u.CreatedAt)
+	return u, err
+}
+
+// UsersByID fetches the given ids with a single IN query. Ids that don't
+// exist are simply absent from the returned map.
+func UsersByID(ctx context.Context, db Querier, ids []int) (map[int]User, error) {
+	users := make(map[int]User, len(ids))
+	if len(ids) == 0 {
+		return users, nil
+	}
+
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	query := `SELECT ` + userColumns + ` FROM users WHERE id IN (?` + strings.Repeat(", ?", len(ids)-1) + `) AND deleted_at IS NULL`
+
+	rows, err := queryRetry(ctx, db, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users[u.ID] = u
+	}
+	return users, rows.Err()
+}
+
+// NormalizeUsername is the form usernames are stored and compared in:
+// surrounding space removed and lower case, so "Alice " and "alice" are
+// the same user.
+func NormalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// UsernameExists reports whether a user with the normalized form of
+// username exists, without fetching the row. Soft-deleted users count,
+// since their usernames stay taken.
+func UsernameExists(ctx context.Context, db Querier, username string) (bool, error) {
+	var exists bool
+	err := db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE username = ?)`,
+		NormalizeUsername(username)).Scan(&exists)
+	return exists, err
+}
+
+// UserByUsername returns the user with the normalized form of username,
+// or an error wrapping ErrUserNotFound. Soft-deleted users are not found.
+func UserByUsername(ctx context.Context, db Querier, username string) (User, error) {
+	username = NormalizeUsername(username)
+	u, err := scanUser(db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE username = ? AND deleted_at IS NULL`, username))
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, fmt.Errorf("%w: username %q", ErrUserNotFound, username)
+	}
+	return u, err
+}
+
+// likeEscaper escapes s for a LIKE pattern written with ESCAPE '!'.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// CreateUser inserts u and sets u.ID to the generated id. u.Username is
+// normalized with NormalizeUsername. u.Password is taken as the plaintext
+// password and replaced by its bcrypt hash, which is what gets stored. A
+// zero CreatedAt is filled in with the current time. A taken username is
+// reported as an error wrapping ErrDuplicateUsername.
+func CreateUser(ctx context.Context, db Querier, u *User) (int64, error) {
+	args, err := insertUserArgs(u)
+	if err != nil {
+		return 0, err
+	}
+	res, err := db.ExecContext(ctx, insertUserQuery, args...)
+	return insertedID(u, res, err)
+}
+
+const insertUserQuery = `INSERT INTO users (username, password, email, created_at) VALUES (?, ?, ?, ?)`
+
+// insertUserArgs normalizes u as described on CreateUser and returns the
+// arguments for insertUserQuery.
+func insertUserArgs(u *User) ([]interface{}, error) {
+	u.Username = NormalizeUsername(u.Username)
+	hash, err := HashPassword(u.Password)
+	if err != nil {
+		return nil, err
+	}
+	u.Password = hash
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = time.Now()
+	}
+	return []interface{}{u.Username, u.Password, u.Email, u.CreatedAt}, nil
+}
+
+// insertedID sets u.ID from the result of insertUserQuery.
+func insertedID(u *User, res sql.Result, err error) (int64, error) {
+	if err != nil {
+		return 0, usernameErr(err, u.Username)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	u.ID = int(id)
+	return id, nil
+}
+
+// ModifyUser loads the user with the given id, lets fn change it and saves
+// the result, all in one transaction holding the row lock, so concurrent
+// modifications cannot interleave. If fn returns an error nothing is saved
+// and the error is returned. The username is normalized and a changed
+// u.Password is taken as a new plaintext password and hashed. Errors wrap
+// ErrUserNotFound or ErrDuplicateUsername as for UserRepository.Update.
+func ModifyUser(ctx context.Context, db TxBeginner, id int, fn func(*User) error) (User, error) {
+	var u User
+	err := RunInTx(ctx, db, func(tx *Tx) error {
+		var err error
+		u, err = scanUser(tx.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = ? AND deleted_at IS NULL FOR UPDATE`, id))
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: id %d", ErrUserNotFound, id)
+		}
+		if err != nil {
+			return err
+		}
+
+		hash := u.Password
+		if err := fn(&u); err != nil {
+			return err
+		}
+		u.ID = id
+		u.Username = NormalizeUsername(u.Username)
+		if u.Password != hash {
+			if u.Password, err = HashPassword(u.Password); err != nil {
+				return err
+			}
+		}
+		_, err = tx.ExecContext(ctx, updateUserQuery, u.Username, u.Email, u.Password, u.ID)
+		return usernameErr(err, u.Username)
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return u, nil
+}
+
+// MaxRecentUsers caps the n accepted by RecentUsers.
+const MaxRecentUsers = 100
+
+// RecentUsers returns the n most recently created users, newest first.
+// Users created in the same second are ordered by descending id. n is
+// clamped to MaxRecentUsers.
+func RecentUsers(ctx context.Context, db Querier, n int) ([]User, error) {
+	if n <= 0 {
+		return []User{}, nil
+	}
+	if n > MaxRecentUsers {
+		n = MaxRecentUsers
+	}
+
+	rows, err := queryRetry(ctx, db, `SELECT `+userColumns+` FROM users WHERE deleted_at IS NULL ORDER BY created_at DESC, id DESC LIMIT ?`, n)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows(rows, scanUser)
+}
+
+// MaxRandomUsers caps the n accepted by RandomUsers.
+const MaxRandomUsers = 100
+
+// RandomUsers returns up to n distinct users in random order, for demo and
+// test data. n is clamped to MaxRandomUsers; fewer users are returned only
+// when the table holds fewer.
+//
+// ORDER BY RAND() assigns a random key to every row and sorts them all, so
+// the query scans the whole table. That is fine at demo sizes but should
+// not run per request against a large table.
+func RandomUsers(ctx context.Context, db Querier, n int) ([]User, error) {
+	if n <= 0 {
+		return []User{}, nil
+	}
+	if n > MaxRandomUsers {
+		n = MaxRandomUsers
+	}
+
+	rows, err := queryRetry(ctx, db, `SELECT `+userColumns+` FROM users WHERE deleted_at IS NULL ORDER BY RAND() LIMIT ?`, n)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows(rows, scanUser)
+}
+
+// DefaultPageSize and MaxPageSize bound the limit accepted by ListUsers.
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// ListUsers returns one page of users ordered by id. A limit outside
+// 1..MaxPageSize is replaced by DefaultPageSize. hasMore reports whether
+// further rows follow; it is found by asking for one row more than limit.
+func ListUsers(ctx context.Context, db Querier, limit, offset int) (users []User, hasMore bool, err error) {
+	return listUsersPage(ctx, db, "", nil, limit, offset)
+}
+
+// listUsersPage is ListUsers for the users matching cond, an SQL condition
+// with placeholders for args, or for all of them when cond is empty.
+func listUsersPage(ctx context.Context, db Querier, cond string, args []interface{}, limit, offset int) (users []User, hasMore bool, err error) {
+	if limit < 1 || limit > MaxPageSize {
+		limit = DefaultPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	where := "deleted_at IS NULL"
+	if cond != "" {
+		where += " AND " + cond
+	}
+	args = append(append([]interface{}(nil), args...), limit+1, offset)
+	rows, err := queryRetry(ctx, db, `SELECT `+userColumns+` FROM users WHERE `+where+` ORDER BY id LIMIT ? OFFSET ?`, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	if users, err = collectRows(rows, scanUser); err != nil {
+		return nil, false, err
+	}
+	if len(users) > limit {
+		return users[:limit], true, nil
+	}
+	return users, false, nil
+}
+
+// sortColumns are the columns ListUsersOrdered accepts. ORDER BY can't take
+// a placeholder, so anything not listed here never reaches the query text.
+var sortColumns = map[string]bool{
+	"id":         true,
+	"username":   true,
+	"created_at": true,
+}
+
+// InvalidSortError is returned by ListUsersOrdered for a column outside the
+// allowlist.
+type InvalidSortError struct {
+	Column string
+}
+
+func (e *InvalidSortError) Error() string {
+	return fmt.Sprintf("database: cannot sort users by %q", e.Column)
+}
+
+// ListUsersOrdered returns up to limit users ordered by sortBy, which must be
+// one of id, username or created_at; id breaks ties. A limit outside
+// 1..MaxPageSize is replaced by DefaultPageSize.
+func ListUsersOrdered(ctx context.Context, db Querier, sortBy string, desc bool, limit int) ([]User, error) {
+	if !sortColumns[sortBy] {
+		return nil, &InvalidSortError{Column: sortBy}
+	}
+	if limit < 1 || limit > MaxPageSize {
+		limit = DefaultPageSize
+	}
+
+	dir := "ASC"
+	if desc {
+		dir = "DESC"
+	}
+	order := sortBy + " " + dir
+	if sortBy != "id" {
+		order += ", id " + dir
+	}
+
+	rows, err := queryRetry(ctx, db, `SELECT `+userColumns+` FROM users WHERE deleted_at IS NULL ORDER BY `+order+` LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows(rows, scanUser)
+}
+
+// UserSignupsByDay counts the users created on each day from the day of
+// from through the day of to, both included. Keys are "2006-01-02" dates
+// and days without signups are present with a count of zero. Deleted users
+// are not counted.
+//
+// Days are UTC days, whatever the location of from and to: created_at is
+// written in UTC by the driver, so that is what DATE(created_at) groups by.
+func UserSignupsByDay(ctx context.Context, db Querier, from, to time.Time) (map[string]int, error) {
+	const layout = "2006-01-02"
+	from, to = from.UTC(), to.UTC()
+	first := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	end := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+
+	counts := make(map[string]int)
+	for d := first; d.Before(end); d = d.AddDate(0, 0, 1) {
+		counts[d.Format(layout)] = 0
+	}
+	if len(counts) == 0 {
+		return counts, nil
+	}
+
+	rows, err := queryRetry(ctx, db, `SELECT DATE_FORMAT(DATE(created_at), '%Y-%m-%d') AS day, COUNT(*)
+		FROM users WHERE created_at >= ? AND created_at < ? AND deleted_at IS NULL
+		GROUP BY DATE(created_at)`, first, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var day string
+		var n int
+		if err := rows.Scan(&day, &n); err != nil {
+			return nil, err
+		}
+		counts[day] = n
+	}
+	return counts, rows.Err()
+}
@@ -0,0 +1,310 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestCreateUserDuplicateUsername(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectExec(insertUserQuery).
+		WithArgs("alice", sqlmock.AnyArg(), "", sqlmock.AnyArg()).
+		WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'alice' for key 'users_username'"})
+
+	u := User{Username: " Alice", Password: "secret"}
+	_, err := CreateUser(context.Background(), db, &u)
+	if !errors.Is(err, ErrDuplicateUsername) {
+		t.Fatalf("CreateUser error = %v, want ErrDuplicateUsername", err)
+	}
+	if u.ID != 0 {
+		t.Errorf("u.ID = %d after failed insert, want 0", u.ID)
+	}
+}
+
+func TestCreateUserOtherErrorsPassThrough(t *testing.T) {
+	db, mock := newMock(t)
+	dbErr := &mysql.MySQLError{Number: 1146, Message: "Table 'users' doesn't exist"}
+	mock.ExpectExec(insertUserQuery).WillReturnError(dbErr)
+
+	u := User{Username: "alice", Password: "secret"}
+	_, err := CreateUser(context.Background(), db, &u)
+	if errors.Is(err, ErrDuplicateUsername) || !errors.Is(err, dbErr) {
+		t.Fatalf("CreateUser error = %v, want the driver error unchanged", err)
+	}
+}
+
+func TestUsernameExists(t *testing.T) {
+	const query = `SELECT EXISTS(SELECT 1 FROM users WHERE username = ?)`
+	tests := []struct {
+		username string
+		found    bool
+	}{
+		{" Alice", true},
+		{"nobody", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.username, func(t *testing.T) {
+			db, mock := newMock(t)
+			mock.ExpectQuery(query).WithArgs(NormalizeUsername(tt.username)).
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(tt.found))
+
+			got, err := UsernameExists(context.Background(), db, tt.username)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.found {
+				t.Errorf("UsernameExists(%q) = %v, want %v", tt.username, got, tt.found)
+			}
+		})
+	}
+}
+
+const signupsQuery = `SELECT DATE_FORMAT(DATE(created_at), '%Y-%m-%d') AS day, COUNT(*)
+		FROM users WHERE created_at >= ? AND created_at < ? AND deleted_at IS NULL
+		GROUP BY DATE(created_at)`
+
+func TestUserSignupsByDayFillsGaps(t *testing.T) {
+	db, mock := newMock(t)
+	from := time.Date(2024, 3, 1, 15, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(signupsQuery).
+		WithArgs(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC)).
+		WillReturnRows(sqlmock.NewRows([]string{"day", "count"}).
+			AddRow("2024-03-01", 3).
+			AddRow("2024-03-04", 1))
+
+	got, err := UserSignupsByDay(context.Background(), db, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{
+		"2024-03-01": 3,
+		"2024-03-02": 0,
+		"2024-03-03": 0,
+		"2024-03-04": 1,
+		"2024-03-05": 0,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UserSignupsByDay = %v, want %v", got, want)
+	}
+}
+
+func TestUserSignupsByDayUsesUTCDays(t *testing.T) {
+	db, mock := newMock(t)
+	// 05:00 on 1 March in UTC+10 is still 29 February in UTC.
+	sydney := time.FixedZone("AEST", 10*60*60)
+	from := time.Date(2024, 3, 1, 5, 0, 0, 0, sydney)
+	to := time.Date(2024, 3, 1, 23, 0, 0, 0, sydney)
+	mock.ExpectQuery(signupsQuery).
+		WithArgs(time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)).
+		WillReturnRows(sqlmock.NewRows([]string{"day", "count"}).AddRow("2024-02-29", 2))
+
+	got, err := UserSignupsByDay(context.Background(), db, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"2024-02-29": 2, "2024-03-01": 0}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UserSignupsByDay = %v, want %v", got, want)
+	}
+}
+
+func TestUserSignupsByDayEmptyWindow(t *testing.T) {
+	db, _ := newMock(t)
+	from := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	got, err := UserSignupsByDay(context.Background(), db, from, from.AddDate(0, 0, -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("UserSignupsByDay = %v, want no days", got)
+	}
+}
+
+func TestUsersByIDSkipsMissing(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectQuery(`SELECT `+userColumns+` FROM users WHERE id IN (?, ?, ?) AND deleted_at IS NULL`).
+		WithArgs(1, 7, 2).
+		WillReturnRows(userRows(alice, bob))
+
+	got, err := UsersByID(context.Background(), db, []int{1, 7, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]User{1: alice, 2: bob}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UsersByID = %v, want %v", got, want)
+	}
+}
+
+func TestUsersByIDNoIDs(t *testing.T) {
+	db, _ := newMock(t)
+	got, err := UsersByID(context.Background(), db, nil)
+	if err != nil || len(got) != 0 {
+		t.Fatalf("UsersByID(nil) = %v, %v; want an empty map and no query", got, err)
+	}
+}
+
+const recentUsersQuery = `SELECT ` + userColumns + ` FROM users WHERE deleted_at IS NULL ORDER BY created_at DESC, id DESC LIMIT ?`
+
+func TestRecentUsersOrderAndTieBreak(t *testing.T) {
+	db, mock := newMock(t)
+	// carol and bob were created in the same second; the higher id is the
+	// later insert and comes first.
+	same := time.Date(2024, 3, 3, 9, 0, 0, 0, time.UTC)
+	carol := User{ID: 3, Username: "carol", CreatedAt: same}
+	dave := User{ID: 4, Username: "dave", CreatedAt: same}
+	mock.ExpectQuery(recentUsersQuery).
+		WithArgs(3).
+		WillReturnRows(userRows(dave, carol, bob))
+
+	got, err := RecentUsers(context.Background(), db, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []User{dave, carol, bob}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RecentUsers = %+v, want %+v", got, want)
+	}
+}
+
+func TestRecentUsersClampsN(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectQuery(recentUsersQuery).
+		WithArgs(MaxRecentUsers).
+		WillReturnRows(userRows())
+
+	if _, err := RecentUsers(context.Background(), db, MaxRecentUsers+50); err != nil {
+		t.Fatal(err)
+	}
+	got, err := RecentUsers(context.Background(), db, 0)
+	if err != nil || got == nil || len(got) != 0 {
+		t.Errorf("RecentUsers(0) = %v, %v; want an empty slice without a query", got, err)
+	}
+}
+
+func TestListUsersOrderedAllowedColumns(t *testing.T) {
+	tests := []struct {
+		sortBy string
+		desc   bool
+		order  string
+	}{
+		{"id", false, "id ASC"},
+		{"id", true, "id DESC"},
+		{"username", false, "username ASC, id ASC"},
+		{"created_at", true, "created_at DESC, id DESC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.order, func(t *testing.T) {
+			db, mock := newMock(t)
+			mock.ExpectQuery(`SELECT ` + userColumns + ` FROM users WHERE deleted_at IS NULL ORDER BY ` + tt.order + ` LIMIT ?`).
+				WithArgs(10).WillReturnRows(userRows(bob, alice))
+
+			users, err := ListUsersOrdered(context.Background(), db, tt.sortBy, tt.desc, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(users, []User{bob, alice}) {
+				t.Errorf("users = %+v, want the rows in the order returned", users)
+			}
+		})
+	}
+}
+
+func TestListUsersOrderedRejectsInjection(t *testing.T) {
+	// No expectations: a rejected column must not reach the database.
+	db, _ := newMock(t)
+	for _, col := range []string{"id; DROP TABLE users", "(SELECT password FROM users LIMIT 1)", "password", ""} {
+		_, err := ListUsersOrdered(context.Background(), db, col, false, 10)
+		var sortErr *InvalidSortError
+		if !errors.As(err, &sortErr) || sortErr.Column != col {
+			t.Errorf("ListUsersOrdered(%q) error = %v, want *InvalidSortError", col, err)
+		}
+	}
+}
+
+const randomUsersQuery = `SELECT ` + userColumns + ` FROM users WHERE deleted_at IS NULL ORDER BY RAND() LIMIT ?`
+
+// shuffledUsers returns rows for n users with distinct ids in no
+// particular order, as ORDER BY RAND() would.
+func shuffledUsers(n int) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "username", "password", "email", "created_at"})
+	for i := 0; i < n; i++ {
+		id := (i*7)%n + 1
+		rows.AddRow(id, fmt.Sprintf("user%d", id), "", "", time.Now())
+	}
+	return rows
+}
+
+func TestRandomUsers(t *testing.T) {
+	tests := []struct {
+		name    string
+		n, want int
+	}{
+		{"requested count", 5, 5},
+		{"clamped", MaxRandomUsers + 50, MaxRandomUsers},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMock(t)
+			mock.ExpectQuery(randomUsersQuery).WithArgs(tt.want).WillReturnRows(shuffledUsers(tt.want))
+
+			users, err := RandomUsers(context.Background(), db, tt.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(users) != tt.want {
+				t.Fatalf("got %d users, want %d", len(users), tt.want)
+			}
+			seen := make(map[int]bool)
+			for _, u := range users {
+				if seen[u.ID] {
+					t.Errorf("user %d returned twice", u.ID)
+				}
+				seen[u.ID] = true
+			}
+		})
+	}
+}
+
+func TestRandomUsersNone(t *testing.T) {
+	db, _ := newMock(t)
+	if users, err := RandomUsers(context.Background(), db, 0); err != nil || len(users) != 0 {
+		t.Errorf("RandomUsers(0) = %v, %v; want no users and no query", users, err)
+	}
+}
+
+func TestListUsersByMetadata(t *testing.T) {
+	db, mock := newMock(t)
+	// Of users seeded with plan pro, free and none, the query keeps the
+	// two with plan "pro"; the mock answers with that subset.
+	mock.ExpectQuery(`SELECT `+userColumns+` FROM users WHERE deleted_at IS NULL AND JSON_UNQUOTE(JSON_EXTRACT(metadata, '$.plan')) = ? ORDER BY id LIMIT ? OFFSET ?`).
+		WithArgs("pro", 3, 0).
+		WillReturnRows(userRows(alice, bob))
+
+	users, hasMore, err := ListUsersByMetadata(context.Background(), db, "plan", "pro", 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[0].ID != alice.ID || users[1].ID != bob.ID || hasMore {
+		t.Errorf("got %v, hasMore %v; want alice and bob and no more", users, hasMore)
+	}
+}
+
+func TestListUsersByMetadataRejectsKeys(t *testing.T) {
+	db, _ := newMock(t)
+	for _, key := range []string{"", "plan')) = 1 OR 1=1 -- ", "a.b", "$[0]", "9lives", strings.Repeat("k", 65)} {
+		_, _, err := ListUsersByMetadata(context.Background(), db, key, "x", 10, 0)
+		var keyErr *InvalidMetadataKeyError
+		if !errors.As(err, &keyErr) || keyErr.Key != key {
+			t.Errorf("key %q: error = %v, want InvalidMetadataKeyError", key, err)
+		}
+	}
+}
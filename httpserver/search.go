@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	"golang/web"
+)
+
+// maxSearchResults bounds the limit parameter of /search.
+const maxSearchResults = 100
+
+// errEnoughResults ends the walk once limit results are found.
+var errEnoughResults = errors.New("enough results")
+
+// search answers /search?q=<text>&limit=<n> with the /static/ URLs of the
+// assets whose path contains q, ignoring case. Dotfiles are skipped, as
+// staticFiles refuses to serve them.
+func search(assets fs.FS) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := web.RequireQuery(r, "q")
+		if err != nil {
+			web.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		limit := web.QueryInt(r, "limit", 10)
+		if limit < 1 || limit > maxSearchResults {
+			limit = 10
+		}
+
+		q = strings.ToLower(q)
+		results := []string{}
+		err = fs.WalkDir(assets, ".", func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if p != "." && strings.HasPrefix(d.Name(), ".") {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if !d.IsDir() && strings.Contains(strings.ToLower(p), q) {
+				results = append(results, path.Join("/static", p))
+				if len(results) == limit {
+					return errEnoughResults
+				}
+			}
+			return nil
+		})
+		if err != nil && err != errEnoughResults {
+			log.Printf("search %q: %v", q, err)
+			web.WriteError(w, http.StatusInternalServerError, "search failed")
+			return
+		}
+		web.WriteJSON(w, http.StatusOK, results)
+	}
+}
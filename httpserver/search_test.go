@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSearch(t *testing.T) {
+	assets := fstest.MapFS{
+		"css/site.css":  {Data: []byte("body {}")},
+		"css/print.CSS": {Data: []byte("")},
+		"js/site.js":    {Data: []byte("")},
+		".env":          {Data: []byte("SECRET=1")},
+		".git/config":   {Data: []byte("")},
+	}
+	h := search(assets)
+	tests := []struct {
+		query string
+		code  int
+		want  []string
+	}{
+		{"q=css", http.StatusOK, []string{"/static/css/print.CSS", "/static/css/site.css"}},
+		{"q=site&limit=1", http.StatusOK, []string{"/static/css/site.css"}},
+		{"q=site&limit=abc", http.StatusOK, []string{"/static/css/site.css", "/static/js/site.js"}},
+		{"q=env", http.StatusOK, []string{}},
+		{"limit=5", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h(rec, httptest.NewRequest(http.MethodGet, "/search?"+tt.query, nil))
+		if rec.Code != tt.code {
+			t.Errorf("%s: status = %d, want %d", tt.query, rec.Code, tt.code)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+		var got []string
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: results = %s, want %q", tt.query, rec.Body, tt.want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"golang/web"
+)
+
+// staticFS holds the assets compiled into the binary.
+//
+//go:embed static
+var staticFS embed.FS
+
+// Server serves the welcome page and the static assets under /static/.
+// The assets come from the binary unless DevMode is set, in which case
+// they are read from StaticDir on every request so edits show up without
+// a rebuild. Zero Timeouts fields take web.DefaultTimeouts. When CertFile
+// and KeyFile are set it serves HTTPS with web.TLSConfig.
+type Server struct {
+	Addr      string
+	DevMode   bool
+	StaticDir string
+	Timeouts  web.Timeouts
+	CertFile  string
+	KeyFile   string
+
+	once sync.Once
+	srv  *http.Server
+}
+
+func (s *Server) httpServer() *http.Server {
+	s.once.Do(func() {
+		s.srv = web.NewServer(s.Addr, s.routes(), s.Timeouts)
+	})
+	return s.srv
+}
+
+// staticMaxAge is how long clients may use an asset before revalidating
+// it. Asset URLs don't change with their contents, so it is kept short.
+const staticMaxAge = time.Hour
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "Welcome to my website!")
+	})
+
+	assets := s.staticFS()
+	maxAge := staticMaxAge
+	if s.DevMode {
+		maxAge = 0
+	}
+	mux.Handle("/static/", http.StripPrefix("/static/", staticFiles(http.FS(assets), maxAge)))
+	mux.Handle("/search", search(assets))
+	return mux
+}
+
+func (s *Server) staticFS() fs.FS {
+	if s.DevMode {
+		return os.DirFS(s.StaticDir)
+	}
+	sub, err := fs.Sub(staticFS, "static")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// Start listens on Addr and serves until Shutdown is called, after which
+// it returns http.ErrServerClosed.
+func (s *Server) Start() error {
+	if s.CertFile != "" {
+		cfg, err := web.TLSConfig(s.CertFile, s.KeyFile)
+		if err != nil {
+			return err
+		}
+		srv := s.httpServer()
+		srv.TLSConfig = cfg
+		return web.ListenAndServe(srv)
+	}
+	return web.ListenAndServe(s.httpServer())
+}
+
+// Shutdown stops accepting connections and waits for active requests to
+// finish, or for ctx to expire.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer().Shutdown(ctx)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServerStartAndShutdown(t *testing.T) {
+	s := &Server{Addr: "127.0.0.1:0"}
+	addrc := make(chan net.Addr, 1)
+	s.httpServer().BaseContext = func(ln net.Listener) context.Context {
+		addrc <- ln.Addr()
+		return context.Background()
+	}
+
+	errc := make(chan error, 1)
+	go func() { errc <- s.Start() }()
+
+	var addr net.Addr
+	select {
+	case addr = <-addrc:
+	case err := <-errc:
+		t.Fatalf("Start: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server didn't start listening")
+	}
+
+	resp, err := http.Get("http://" + addr.String() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "Welcome to my website!" {
+		t.Errorf("GET / = %d %q", resp.StatusCode, body)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		t.Fatalf("Start returned %v, want http.ErrServerClosed", err)
+	}
+}
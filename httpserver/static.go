@@ -0,0 +1,94 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// staticFiles wraps http.FileServer for use in production: paths with a
+// ".." segment or a segment starting with a dot (.env, .git/...) are
+// refused with 403, and directories are answered with 404 instead of a
+// generated listing.
+//
+// Files get a strong ETag, so a matching If-None-Match is answered with
+// 304, and a Cache-Control of maxAge; with maxAge 0 clients revalidate on
+// every use.
+func staticFiles(fsys http.FileSystem, maxAge time.Duration) http.Handler {
+	files := http.FileServer(fsys)
+	cacheControl := "no-cache"
+	if maxAge > 0 {
+		cacheControl = fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds()))
+	}
+	var tags etagCache
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, seg := range strings.Split(r.URL.Path, "/") {
+			if strings.HasPrefix(seg, ".") {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+		}
+
+		name := path.Clean("/" + r.URL.Path)
+		f, err := fsys.Open(name)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		info, err := f.Stat()
+		if err != nil || info.IsDir() {
+			f.Close()
+			http.NotFound(w, r)
+			return
+		}
+		etag, err := tags.get(name, f, info)
+		f.Close()
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		// FileServer answers If-None-Match itself once ETag is set.
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", cacheControl)
+		files.ServeHTTP(w, r)
+	})
+}
+
+// etagCache computes ETags for static files. Files on disk get one from
+// their modification time and size. Embedded files have no modification
+// time, so theirs is a hash of the contents, computed once per path since
+// they can't change while the binary runs.
+type etagCache struct {
+	mu     sync.Mutex
+	hashes map[string]string
+}
+
+func (c *etagCache) get(name string, f io.Reader, info fs.FileInfo) (string, error) {
+	if !info.ModTime().IsZero() {
+		return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()), nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if tag, ok := c.hashes[name]; ok {
+		return tag, nil
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	tag := `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+	if c.hashes == nil {
+		c.hashes = make(map[string]string)
+	}
+	c.hashes[name] = tag
+	return tag, nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// staticDir lays out a static directory with an asset, a dotfile and a
+// subdirectory, next to a main.go that must not be reachable.
+func staticDir(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"main.go":              "package main",
+		"static/css/style.css": "body {}",
+		"static/.env":          "SECRET=1",
+	}
+	for name, data := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(root, "static")
+}
+
+func TestStaticFilesBlocksTraversalAndDotfiles(t *testing.T) {
+	h := http.StripPrefix("/static/", staticFiles(http.Dir(staticDir(t)), 0))
+	tests := []struct {
+		target string
+		want   int
+	}{
+		{"/static/../main.go", http.StatusForbidden},
+		{"/static/.env", http.StatusForbidden},
+		{"/static/css/../.env", http.StatusForbidden},
+		{"/static/css/", http.StatusNotFound},
+		{"/static/css", http.StatusNotFound},
+		{"/static/css/style.css", http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if rec.Code != tt.want {
+			t.Errorf("GET %s = %d, want %d", tt.target, rec.Code, tt.want)
+		}
+		if body := rec.Body.String(); strings.Contains(body, "package main") || strings.Contains(body, "SECRET") {
+			t.Errorf("GET %s leaked a file: %q", tt.target, body)
+		}
+	}
+}
+
+func TestServerStaticTraversal(t *testing.T) {
+	s := &Server{DevMode: true, StaticDir: staticDir(t)}
+	for _, target := range []string{"/static/../main.go", "/static/.env"} {
+		rec := httptest.NewRecorder()
+		s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code == http.StatusOK {
+			t.Errorf("GET %s = 200, want it blocked", target)
+		}
+		if body := rec.Body.String(); strings.Contains(body, "package main") || strings.Contains(body, "SECRET") {
+			t.Errorf("GET %s leaked a file: %q", target, body)
+		}
+	}
+}
+
+func TestServerServesEmbeddedFile(t *testing.T) {
+	want, err := staticFS.ReadFile("static/css/styles.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	(&Server{}).routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/static/css/styles.css", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), want) {
+		t.Errorf("body doesn't match the embedded file: got %d bytes, want %d", rec.Body.Len(), len(want))
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/css") {
+		t.Errorf("Content-Type = %q, want text/css", ct)
+	}
+}
+
+func TestServerDevModeReadsDisk(t *testing.T) {
+	dir := staticDir(t)
+	if err := os.WriteFile(filepath.Join(dir, "css", "style.css"), []byte("edited"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	(&Server{DevMode: true, StaticDir: dir}).routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/static/css/style.css", nil))
+	if rec.Body.String() != "edited" {
+		t.Errorf("body = %q, want the file as it is on disk", rec.Body)
+	}
+}
+
+// getWithETag requests target from h, sending etag as If-None-Match
+// unless it is empty.
+func getWithETag(h http.Handler, target, etag string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestStaticFilesETag(t *testing.T) {
+	dir := staticDir(t)
+	h := http.StripPrefix("/static/", staticFiles(http.Dir(dir), time.Hour))
+
+	first := getWithETag(h, "/static/css/style.css", "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || !strings.HasPrefix(etag, `"`) {
+		t.Fatalf("GET = %d with ETag %q, want 200 and a strong ETag", first.Code, etag)
+	}
+	if cc := first.Header().Get("Cache-Control"); cc != "public, max-age=3600" {
+		t.Errorf("Cache-Control = %q, want public, max-age=3600", cc)
+	}
+
+	if rec := getWithETag(h, "/static/css/style.css", etag); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("GET with If-None-Match = %d %q, want an empty 304", rec.Code, rec.Body)
+	}
+
+	// Editing the file changes the tag, so the stale one gets the new file.
+	p := filepath.Join(dir, "css", "style.css")
+	if err := os.WriteFile(p, []byte("body { color: red }"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(p, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if rec := getWithETag(h, "/static/css/style.css", etag); rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+		t.Errorf("GET after an edit = %d with ETag %q, want 200 and a new tag", rec.Code, rec.Header().Get("ETag"))
+	}
+}
+
+func TestStaticFilesETagEmbedded(t *testing.T) {
+	h := (&Server{}).routes()
+	etag := getWithETag(h, "/static/css/styles.css", "").Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag on an embedded file")
+	}
+	if rec := getWithETag(h, "/static/css/styles.css", etag); rec.Code != http.StatusNotModified {
+		t.Errorf("GET with If-None-Match = %d, want 304", rec.Code)
+	}
+	if again := getWithETag(h, "/static/css/styles.css", "").Header().Get("ETag"); again != etag {
+		t.Errorf("ETag changed from %q to %q between requests", etag, again)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"golang/web"
+)
+
+// BasicAuthMiddleware only lets requests through whose Basic credentials
+// match users, which maps usernames to bcrypt hashes of their passwords.
+// Unknown usernames are checked against a dummy hash so they take as long
+// as a wrong password. With no users the routes are disabled and answer
+// 404. main puts every admin route behind it.
+func BasicAuthMiddleware(users map[string]string) func(http.Handler) http.Handler {
+	dummy, err := bcrypt.GenerateFromPassword([]byte("no such user"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(err)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(users) == 0 {
+				web.WriteError(w, http.StatusNotFound, "not found")
+				return
+			}
+			varyOn(r, "Authorization")
+			name, pass, ok := r.BasicAuth()
+			hash, known := users[name]
+			if !known {
+				hash = string(dummy)
+			}
+			err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(pass))
+			if !ok || !known || err != nil {
+				w.Header().Set("WWW-Authenticate", `Basic realm="admin", charset="UTF-8"`)
+				web.WriteError(w, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// debugConfig reports the effective, non-secret configuration.
+func debugConfig(cfg config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		web.WriteJSON(w, http.StatusOK, cfg.public())
+	}
+}
+
+// redactedHeaders are replaced by "[redacted]" in /debug/headers.
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// debugHeaders echoes the request headers as JSON, for checking what a
+// reverse proxy forwards. It answers 404 unless enabled, which main does
+// when FEATURES contains "debug-headers".
+func debugHeaders(enabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !enabled {
+			web.WriteError(w, http.StatusNotFound, "not found")
+			return
+		}
+		h := r.Header.Clone()
+		for _, name := range redactedHeaders {
+			if _, ok := h[name]; ok {
+				h[name] = []string{"[redacted]"}
+			}
+		}
+		web.WriteJSON(w, http.StatusOK, h)
+	}
+}
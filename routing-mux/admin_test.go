@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestBasicAuthMiddleware(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := BasicAuthMiddleware(map[string]string{"root": string(hash)})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("admin"))
+	}))
+
+	tests := []struct {
+		name       string
+		user, pass string
+		header     bool
+		want       int
+	}{
+		{"missing credentials", "", "", false, http.StatusUnauthorized},
+		{"wrong password", "root", "guess", true, http.StatusUnauthorized},
+		{"unknown user", "mallory", "s3cret", true, http.StatusUnauthorized},
+		{"correct password", "root", "s3cret", true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/latency", nil)
+			if tt.header {
+				req.SetBasicAuth(tt.user, tt.pass)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			challenge := rec.Header().Get("WWW-Authenticate")
+			if tt.want == http.StatusUnauthorized && !strings.HasPrefix(challenge, "Basic ") {
+				t.Errorf("WWW-Authenticate = %q, want a Basic challenge", challenge)
+			}
+			if tt.want == http.StatusOK && rec.Body.String() != "admin" {
+				t.Errorf("body = %q, want the handler's", rec.Body)
+			}
+		})
+	}
+}
+
+func TestBasicAuthMiddlewareWithoutUsers(t *testing.T) {
+	h := BasicAuthMiddleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler ran with no admin users configured")
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/debug/config", nil)
+	req.SetBasicAuth("root", "s3cret")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", rec.Code)
+	}
+}
+
+func TestDebugHeaders(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/debug/headers", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	req.Header.Set("Cookie", "session=abc")
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+
+	rec := httptest.NewRecorder()
+	debugHeaders(true)(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var got http.Header
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if v := got.Get("Authorization"); v != "[redacted]" {
+		t.Errorf("Authorization = %q, want [redacted]", v)
+	}
+	if v := got.Get("Cookie"); v != "[redacted]" {
+		t.Errorf("Cookie = %q, want [redacted]", v)
+	}
+	if v := got.Get("X-Forwarded-For"); v != "10.0.0.1" {
+		t.Errorf("X-Forwarded-For = %q, want it echoed", v)
+	}
+	if strings.Contains(rec.Body.String(), "s3cret") || strings.Contains(rec.Body.String(), "abc") {
+		t.Errorf("body leaks a secret: %s", rec.Body)
+	}
+	if req.Header.Get("Authorization") != "Bearer s3cret" {
+		t.Error("the request's own header was redacted")
+	}
+}
+
+func TestDebugHeadersDisabled(t *testing.T) {
+	rec := httptest.NewRecorder()
+	debugHeaders(false)(rec, httptest.NewRequest(http.MethodGet, "/debug/headers", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"regexp"
+)
+
+type bodySnapshotKey struct{}
+
+// Values of these fields are replaced before a body snapshot is logged. A
+// value cut off by the size limit is still redacted.
+var (
+	sensitiveJSON = regexp.MustCompile(`("(?i:password|passwd|token|secret|api_key|authorization)"\s*:\s*)"[^"]*("|$)`)
+	sensitiveForm = regexp.MustCompile(`((?:^|&)(?i:password|passwd|token|secret|api_key)=)[^&]*`)
+)
+
+// logBodies captures the first limit bytes of every request body, with
+// sensitive fields redacted, for LoggingMiddleware and RecoverMiddleware to
+// log. Only that prefix is buffered: the handler still reads the complete
+// body, the buffered part followed by the rest of the stream. A limit of 0
+// disables body logging.
+func logBodies(limit int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body == nil || r.Body == http.NoBody || quietRequest(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			head := make([]byte, limit)
+			n, err := io.ReadFull(r.Body, head)
+			head = head[:n]
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(head), r.Body), r.Body}
+
+			truncated := err == nil && (r.ContentLength < 0 || r.ContentLength > int64(n))
+			snapshot := redactBody(head)
+			if truncated {
+				snapshot += "...(truncated)"
+			}
+			r, slot := withBodySnapshot(r)
+			*slot = snapshot
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// withBodySnapshot returns r with a slot for logBodies to leave the body
+// snapshot in. The loggers run outside logBodies and never see the request
+// it passes on, so they make the slot and logBodies fills it; a request
+// that already has one is returned as is.
+func withBodySnapshot(r *http.Request) (*http.Request, *string) {
+	if slot, ok := r.Context().Value(bodySnapshotKey{}).(*string); ok {
+		return r, slot
+	}
+	slot := new(string)
+	return r.WithContext(context.WithValue(r.Context(), bodySnapshotKey{}, slot)), slot
+}
+
+func redactBody(body []byte) string {
+	body = sensitiveJSON.ReplaceAll(body, []byte(`$1"[REDACTED]"`))
+	body = sensitiveForm.ReplaceAll(body, []byte(`${1}[REDACTED]`))
+	return string(body)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// captureLog sends the standard logger to a buffer until the test ends.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	out, flags := log.Writer(), log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(out)
+		log.SetFlags(flags)
+	})
+	return &buf
+}
+
+func TestLogBodiesPassesFullBodyAndLogsSnapshot(t *testing.T) {
+	logs := captureLog(t)
+	body := `{"username":"alice","password":"hunter2","bio":"` + strings.Repeat("x", 100) + `"}`
+
+	var got []byte
+	h := NewChain(LoggingMiddleware, logBodies(48)).Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = io.ReadAll(r.Body)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body)))
+
+	if string(got) != body {
+		t.Errorf("handler read %d bytes, want the full %d", len(got), len(body))
+	}
+	line := logs.String()
+	if strings.Contains(line, "hunter2") {
+		t.Errorf("log has the password: %s", line)
+	}
+	if !strings.Contains(line, `\"password\":\"[REDACTED]\"`) {
+		t.Errorf("log lacks the redacted password: %s", line)
+	}
+	if !strings.Contains(line, "...(truncated)") || strings.Contains(line, strings.Repeat("x", 100)) {
+		t.Errorf("log snapshot isn't truncated: %s", line)
+	}
+	if n := strings.Count(line, "\n"); n != 1 {
+		t.Errorf("got %d log lines, want the one access line:\n%s", n, line)
+	}
+}
+
+func TestLogBodiesRedactsForm(t *testing.T) {
+	logs := captureLog(t)
+	h := NewChain(LoggingMiddleware, logBodies(1024)).Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/login", strings.NewReader("user=alice&password=hunter2")))
+
+	if line := logs.String(); strings.Contains(line, "hunter2") || !strings.Contains(line, "password=[REDACTED]") {
+		t.Errorf("form password not redacted: %s", line)
+	}
+}
+
+func TestRecoverLogsBodySnapshot(t *testing.T) {
+	logs := captureLog(t)
+	h := NewChain(RecoverMiddleware, logBodies(1024)).Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/books/x", strings.NewReader(`{"title":"x","token":"abc"}`)))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", rec.Code)
+	}
+	line := logs.String()
+	if !strings.Contains(line, `\"title\":\"x\"`) || strings.Contains(line, "abc") {
+		t.Errorf("panic log lacks the redacted body: %s", line)
+	}
+}
+
+func TestLogBodiesDisabled(t *testing.T) {
+	logs := captureLog(t)
+	h := NewChain(LoggingMiddleware, logBodies(0)).Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"username":"alice"}`)))
+
+	if strings.Contains(logs.String(), "body=") {
+		t.Errorf("body logged with a limit of 0: %s", logs)
+	}
+}
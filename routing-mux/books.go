@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+
+	"golang/web"
+)
+
+func bookPage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	page, err := atoiVar(vars, "page")
+	if err != nil {
+		web.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	fmt.Fprintf(w, "You've requested the book: %s on page %d\n", vars["title"], page)
+}
+
+// book is keyed by its title, which comes from the URL rather than the
+// request body.
+type book struct {
+	Title  string `json:"title"`
+	Author string `json:"author"`
+	Pages  int    `json:"pages"`
+}
+
+// String is the plain-text form of b sent by web.Respond.
+func (b book) String() string {
+	return fmt.Sprintf("%s by %s, %d pages", b.Title, b.Author, b.Pages)
+}
+
+type bookRequest struct {
+	Author string `json:"author"`
+	Pages  int    `json:"pages"`
+}
+
+// bookStore keeps books in memory; they are lost on restart.
+type bookStore struct {
+	mu    sync.RWMutex
+	books map[string]book
+}
+
+func newBookStore() *bookStore {
+	return &bookStore{books: make(map[string]book)}
+}
+
+// routes returns the book endpoints, which openapi.json describes. cache
+// wraps the page route, and checkBody the routes that take a JSON body.
+// "/books/batch-get" comes first so "batch-get" isn't taken for a title.
+func (s *bookStore) routes(cache, checkBody func(http.Handler) http.Handler) []Route {
+	return []Route{
+		{Method: "POST", Path: "/books/batch-get", Handler: http.HandlerFunc(s.batchGet), Middlewares: middlewares(requireBody, checkBody)},
+		{Method: "POST", Path: "/books/{title}", Handler: http.HandlerFunc(s.createBook), Middlewares: middlewares(requireBody, checkBody)},
+		{Method: "GET", Path: "/books/{title}", Handler: http.HandlerFunc(s.readBook)},
+		{Method: "PUT", Path: "/books/{title}", Handler: http.HandlerFunc(s.updateBook), Middlewares: middlewares(requireBody, checkBody)},
+		{Method: "DELETE", Path: "/books/{title}", Handler: http.HandlerFunc(s.deleteBook)},
+		{Method: "GET", Path: "/books/{title}/page/{page:[0-9]+}", Handler: http.HandlerFunc(bookPage), Middlewares: middlewares(cache)},
+	}
+}
+
+func decodeBook(w http.ResponseWriter, r *http.Request) (book, bool) {
+	var req bookRequest
+	if err := web.DecodeJSON(r, &req); err != nil {
+		web.RespondError(w, r, web.DecodeStatus(err), err.Error())
+		return book{}, false
+	}
+	return book{Title: pathVar(r, "title"), Author: req.Author, Pages: req.Pages}, true
+}
+
+// maxBatchTitles caps the titles accepted by one batch-get request.
+const maxBatchTitles = 100
+
+// bookLookup maps titles to their books, nil for missing ones.
+type bookLookup map[string]*book
+
+// String lists one title per line, sorted, for web.Respond.
+func (l bookLookup) String() string {
+	titles := make([]string, 0, len(l))
+	for t := range l {
+		titles = append(titles, t)
+	}
+	sort.Strings(titles)
+	var sb strings.Builder
+	for i, t := range titles {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		if b := l[t]; b != nil {
+			sb.WriteString(b.String())
+		} else {
+			sb.WriteString(t + ": not found")
+		}
+	}
+	return sb.String()
+}
+
+// getMany looks up every title under a single read lock.
+func (s *bookStore) getMany(titles []string) bookLookup {
+	found := make(bookLookup, len(titles))
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, t := range titles {
+		if b, ok := s.books[t]; ok {
+			found[t] = &b
+		} else {
+			found[t] = nil
+		}
+	}
+	return found
+}
+
+// batchGet handles POST /books/batch-get. The body is a JSON array of
+// titles; the response maps each title to its book, or null when there is
+// no such book.
+func (s *bookStore) batchGet(w http.ResponseWriter, r *http.Request) {
+	var titles []string
+	if err := web.DecodeJSON(r, &titles); err != nil {
+		web.RespondError(w, r, web.DecodeStatus(err), err.Error())
+		return
+	}
+	if len(titles) > maxBatchTitles {
+		web.RespondError(w, r, http.StatusBadRequest, fmt.Sprintf("at most %d titles per request", maxBatchTitles))
+		return
+	}
+	web.Respond(w, r, http.StatusOK, s.getMany(titles))
+}
+
+// createBook handles POST /books/{title}. It answers 201 with the book and
+// a Location of its GET URL.
+func (s *bookStore) createBook(w http.ResponseWriter, r *http.Request) {
+	b, ok := decodeBook(w, r)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	_, exists := s.books[b.Title]
+	if !exists {
+		s.books[b.Title] = b
+	}
+	s.mu.Unlock()
+
+	if exists {
+		web.RespondError(w, r, http.StatusConflict, "book already exists")
+		return
+	}
+	// Books are created at the URL they are read from.
+	w.Header().Set("Location", r.URL.EscapedPath())
+	web.Respond(w, r, http.StatusCreated, b)
+}
+
+// readBook handles GET /books/{title}.
+func (s *bookStore) readBook(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	b, ok := s.books[pathVar(r, "title")]
+	s.mu.RUnlock()
+
+	if !ok {
+		web.RespondError(w, r, http.StatusNotFound, "book not found")
+		return
+	}
+	web.Respond(w, r, http.StatusOK, b)
+}
+
+// updateBook handles PUT /books/{title}, replacing an existing book.
+func (s *bookStore) updateBook(w http.ResponseWriter, r *http.Request) {
+	b, ok := decodeBook(w, r)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	_, exists := s.books[b.Title]
+	if exists {
+		s.books[b.Title] = b
+	}
+	s.mu.Unlock()
+
+	if !exists {
+		web.RespondError(w, r, http.StatusNotFound, "book not found")
+		return
+	}
+	web.Respond(w, r, http.StatusOK, b)
+}
+
+// deleteBook handles DELETE /books/{title}.
+func (s *bookStore) deleteBook(w http.ResponseWriter, r *http.Request) {
+	title := pathVar(r, "title")
+
+	s.mu.Lock()
+	_, exists := s.books[title]
+	delete(s.books, title)
+	s.mu.Unlock()
+
+	if !exists {
+		web.RespondError(w, r, http.StatusNotFound, "book not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
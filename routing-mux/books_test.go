@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"golang/web"
+)
+
+// bookRouter mounts the book routes of a new store, as main does.
+func bookRouter() *mux.Router {
+	noop := func(h http.Handler) http.Handler { return h }
+	r := mux.NewRouter()
+	RegisterRoutes(r, newBookStore().routes(noop, noop))
+	return r
+}
+
+// doBook sends a request to h and returns the response.
+func doBook(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	var rd io.Reader
+	if body != "" {
+		rd = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, rd)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func decodeBookResponse(t *testing.T, rec *httptest.ResponseRecorder) book {
+	t.Helper()
+	var b book
+	if err := json.Unmarshal(rec.Body.Bytes(), &b); err != nil {
+		t.Fatalf("body %q: %v", rec.Body, err)
+	}
+	return b
+}
+
+func TestBookHandlers(t *testing.T) {
+	h := bookRouter()
+	dune := book{Title: "dune", Author: "Herbert", Pages: 412}
+
+	rec := doBook(h, http.MethodPost, "/books/dune", `{"author":"Herbert","pages":412}`)
+	if rec.Code != http.StatusCreated || decodeBookResponse(t, rec) != dune {
+		t.Fatalf("POST = %d %s, want 201 with the book", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	if rec := doBook(h, http.MethodPost, "/books/dune", `{"author":"Herbert","pages":412}`); rec.Code != http.StatusConflict {
+		t.Errorf("second POST = %d, want 409", rec.Code)
+	}
+
+	rec = doBook(h, http.MethodGet, "/books/dune", "")
+	if rec.Code != http.StatusOK || decodeBookResponse(t, rec) != dune {
+		t.Fatalf("GET = %d %s, want 200 with the book", rec.Code, rec.Body)
+	}
+
+	rec = doBook(h, http.MethodPut, "/books/dune", `{"author":"Frank Herbert","pages":896}`)
+	dune.Author, dune.Pages = "Frank Herbert", 896
+	if rec.Code != http.StatusOK || decodeBookResponse(t, rec) != dune {
+		t.Fatalf("PUT = %d %s, want 200 with the new book", rec.Code, rec.Body)
+	}
+	if rec := doBook(h, http.MethodGet, "/books/dune", ""); decodeBookResponse(t, rec) != dune {
+		t.Errorf("GET after PUT = %s, want the update", rec.Body)
+	}
+
+	if rec := doBook(h, http.MethodDelete, "/books/dune", ""); rec.Code != http.StatusNoContent || rec.Body.Len() != 0 {
+		t.Fatalf("DELETE = %d %q, want an empty 204", rec.Code, rec.Body)
+	}
+	if rec := doBook(h, http.MethodGet, "/books/dune", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET after DELETE = %d, want 404", rec.Code)
+	}
+}
+
+func TestBookHandlersMissing(t *testing.T) {
+	h := bookRouter()
+	tests := []struct {
+		method, body string
+	}{
+		{http.MethodGet, ""},
+		{http.MethodPut, `{"author":"nobody","pages":1}`},
+		{http.MethodDelete, ""},
+	}
+	for _, tt := range tests {
+		rec := doBook(h, tt.method, "/books/missing", tt.body)
+		if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "book not found") {
+			t.Errorf("%s /books/missing = %d %s, want 404 book not found", tt.method, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestBookRoutesRestrictMethods(t *testing.T) {
+	if rec := doBook(bookRouter(), http.MethodPatch, "/books/dune", `{}`); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PATCH = %d, want 405", rec.Code)
+	}
+}
+
+func TestBookHandlersRejectBadBodies(t *testing.T) {
+	h := bookRouter()
+	tests := []struct {
+		name, body string
+		want       int
+	}{
+		{"malformed", `{"author":`, http.StatusBadRequest},
+		{"unknown field", `{"author":"Herbert","isbn":"0441013597"}`, http.StatusBadRequest},
+		{"oversized", `{"author":"` + strings.Repeat("a", web.MaxJSONBody) + `"}`, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		if rec := doBook(h, http.MethodPost, "/books/dune", tt.body); rec.Code != tt.want {
+			t.Errorf("%s: POST = %d %s, want %d", tt.name, rec.Code, rec.Body, tt.want)
+		}
+	}
+	if rec := doBook(h, http.MethodGet, "/books/dune", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET after rejected POSTs = %d, want 404", rec.Code)
+	}
+}
+
+func TestBatchGet(t *testing.T) {
+	h := bookRouter()
+	doBook(h, http.MethodPost, "/books/dune", `{"author":"Herbert","pages":412}`)
+	doBook(h, http.MethodPost, "/books/emma", `{"author":"Austen","pages":474}`)
+
+	rec := doBook(h, http.MethodPost, "/books/batch-get", `["dune","missing","emma"]`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("batch-get = %d %s, want 200", rec.Code, rec.Body)
+	}
+	var got map[string]*book
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d titles, want 3: %s", len(got), rec.Body)
+	}
+	if b := got["dune"]; b == nil || b.Author != "Herbert" {
+		t.Errorf("dune = %+v, want Herbert's book", b)
+	}
+	if b := got["emma"]; b == nil || b.Author != "Austen" {
+		t.Errorf("emma = %+v, want Austen's book", b)
+	}
+	if b, ok := got["missing"]; !ok || b != nil {
+		t.Errorf("missing = %+v (present %v), want null", b, ok)
+	}
+}
+
+func TestBatchGetCap(t *testing.T) {
+	h := bookRouter()
+	titles := make([]string, maxBatchTitles+1)
+	for i := range titles {
+		titles[i] = fmt.Sprintf("book-%d", i)
+	}
+	atCap, _ := json.Marshal(titles[:maxBatchTitles])
+	overCap, _ := json.Marshal(titles)
+
+	if rec := doBook(h, http.MethodPost, "/books/batch-get", string(atCap)); rec.Code != http.StatusOK {
+		t.Errorf("%d titles = %d, want 200", maxBatchTitles, rec.Code)
+	}
+	rec := doBook(h, http.MethodPost, "/books/batch-get", string(overCap))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "at most") {
+		t.Errorf("%d titles = %d %s, want 400", len(titles), rec.Code, rec.Body)
+	}
+}
+
+func TestBookHandlersNegotiate(t *testing.T) {
+	h := bookRouter()
+	doBook(h, http.MethodPost, "/books/dune", `{"author":"Herbert","pages":412}`)
+
+	req := httptest.NewRequest(http.MethodGet, "/books/dune", nil)
+	req.Header.Set("Accept", "text/plain")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain", ct)
+	}
+	if body := strings.TrimSpace(rec.Body.String()); body != "dune by Herbert, 412 pages" {
+		t.Errorf("body = %q, want the plain-text book", body)
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// responseCache keeps successful GET responses in memory for ttl, keyed by
+// path and the query parameters in params. Requests with any other query
+// parameter are not cached, so clients can't fill the cache by varying a
+// query string the handler ignores. At most max responses are kept; when
+// full, the oldest is dropped. Hits and misses are marked with an X-Cache
+// header.
+//
+// Only the headers the wrapped handler sets are cached. Those that outer
+// middleware set before it ran, such as X-Request-ID or CORS headers, are
+// per request and are left to be set afresh on a hit.
+type responseCache struct {
+	ttl    time.Duration
+	params map[string]bool
+
+	mu      sync.Mutex
+	entries map[string]cachedResponse
+	// order holds the keys in the order they were stored; the slot at
+	// next is the oldest and is reused for the next one.
+	order []cacheSlot
+	next  int
+}
+
+type cachedResponse struct {
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// cacheSlot remembers which entry was stored in a slot of order, so
+// reusing the slot doesn't drop a newer response stored under the same key.
+type cacheSlot struct {
+	key     string
+	expires time.Time
+}
+
+func newResponseCache(ttl time.Duration, max int, params ...string) *responseCache {
+	c := &responseCache{
+		ttl:     ttl,
+		params:  make(map[string]bool, len(params)),
+		entries: make(map[string]cachedResponse, max),
+		order:   make([]cacheSlot, max),
+	}
+	for _, p := range params {
+		c.params[p] = true
+	}
+	return c
+}
+
+// key is the cache key for r, or "" if r has a query parameter outside
+// c.params.
+func (c *responseCache) key(r *http.Request) string {
+	query := r.URL.Query()
+	for name := range query {
+		if !c.params[name] {
+			return ""
+		}
+	}
+	if len(query) == 0 {
+		return r.URL.EscapedPath()
+	}
+	return r.URL.EscapedPath() + "?" + query.Encode()
+}
+
+func (c *responseCache) get(key string) (cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if ok && time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return cachedResponse{}, false
+	}
+	return e, ok
+}
+
+func (c *responseCache) put(key string, e cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.order) == 0 {
+		return
+	}
+	if old := c.order[c.next]; old.key != "" && c.entries[old.key].expires.Equal(old.expires) {
+		delete(c.entries, old.key)
+	}
+	c.order[c.next] = cacheSlot{key: key, expires: e.expires}
+	c.next = (c.next + 1) % len(c.order)
+	c.entries[key] = e
+}
+
+// sweep drops the responses that expired by now, which get would only
+// notice if they were asked for again.
+func (c *responseCache) sweep(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// sweepEvery runs sweep on every tick until ctx is done.
+func (c *responseCache) sweepEvery(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case now := <-t.C:
+			c.sweep(now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *responseCache) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := c.key(r)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if e, ok := c.get(key); ok {
+			for k, vs := range e.header {
+				for _, v := range vs {
+					if !hasValue(w.Header()[k], v) {
+						w.Header().Add(k, v)
+					}
+				}
+			}
+			w.Header().Set("X-Cache", "HIT")
+			w.WriteHeader(e.status)
+			w.Write(e.body)
+			return
+		}
+
+		w.Header().Set("X-Cache", "MISS")
+		cw := &cacheWriter{ResponseWriter: w, status: http.StatusOK, before: w.Header().Clone()}
+		next.ServeHTTP(cw, r)
+
+		// Responses setting cookies are per-client and must not be shared.
+		if cw.status == http.StatusOK && cw.header != nil && cw.header.Get("Set-Cookie") == "" {
+			c.put(key, cachedResponse{
+				status:  cw.status,
+				header:  cw.header,
+				body:    cw.body.Bytes(),
+				expires: time.Now().Add(c.ttl),
+			})
+		}
+	})
+}
+
+// warm requests each path through h before the server starts accepting
+// traffic, so the first real request for it is already a cache hit.
+func (c *responseCache) warm(h http.Handler, paths []string) {
+	for _, path := range paths {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			log.Printf("cache warm %s: %v", path, err)
+			continue
+		}
+		dw := &discardWriter{header: make(http.Header)}
+		h.ServeHTTP(dw, req)
+		if dw.status != http.StatusOK {
+			log.Printf("cache warm %s: got status %d", path, dw.status)
+		}
+	}
+}
+
+// cacheWriter passes the response through while keeping a copy of it.
+// header holds what the handler added to before, the headers as they were
+// when it was called.
+type cacheWriter struct {
+	http.ResponseWriter
+	status int
+	before http.Header
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (cw *cacheWriter) WriteHeader(code int) {
+	if cw.header == nil {
+		cw.status = code
+		cw.header = addedHeaders(cw.before, cw.Header())
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *cacheWriter) Write(b []byte) (int, error) {
+	if cw.header == nil {
+		cw.WriteHeader(http.StatusOK)
+	}
+	cw.body.Write(b)
+	return cw.ResponseWriter.Write(b)
+}
+
+// addedHeaders returns the values in after that before doesn't have.
+func addedHeaders(before, after http.Header) http.Header {
+	added := make(http.Header)
+	for k, vs := range after {
+		for _, v := range vs {
+			if !hasValue(before[k], v) {
+				added[k] = append(added[k], v)
+			}
+		}
+	}
+	return added
+}
+
+func hasValue(values []string, v string) bool {
+	for _, have := range values {
+		if have == v {
+			return true
+		}
+	}
+	return false
+}
+
+// discardWriter is the ResponseWriter for internal warm-up requests.
+type discardWriter struct {
+	header http.Header
+	status int
+}
+
+func (dw *discardWriter) Header() http.Header { return dw.header }
+
+func (dw *discardWriter) WriteHeader(code int) {
+	if dw.status == 0 {
+		dw.status = code
+	}
+}
+
+func (dw *discardWriter) Write(b []byte) (int, error) {
+	if dw.status == 0 {
+		dw.status = http.StatusOK
+	}
+	return len(b), nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// cachedStack is a handler behind c with an outer middleware that sets a
+// fresh X-Request-ID, and for requests with an Origin an ACAO, the way the
+// real router's middleware does. calls counts the handler runs.
+func cachedStack(c *responseCache, calls *int) http.Handler {
+	var n int
+	inner := c.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("page 1"))
+	}))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		w.Header().Set("X-Request-ID", "req-"+strconv.Itoa(n))
+		if origin := r.Header.Get("Origin"); origin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		inner.ServeHTTP(w, r)
+	})
+}
+
+func TestResponseCacheWarmThenHit(t *testing.T) {
+	c := newResponseCache(time.Minute, 100)
+	var calls int
+	h := cachedStack(c, &calls)
+
+	c.warm(h, []string{"/books/dune/page/1"})
+	if calls != 1 {
+		t.Fatalf("handler ran %d times during warm-up, want 1", calls)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/books/dune/page/1", nil))
+	if got := rec.Header().Get("X-Cache"); got != "HIT" {
+		t.Errorf("X-Cache = %q on first request after warm-up, want HIT", got)
+	}
+	if calls != 1 {
+		t.Errorf("handler ran %d times, want the hit served from cache", calls)
+	}
+	if got := rec.Body.String(); got != "page 1" {
+		t.Errorf("body = %q, want %q", got, "page 1")
+	}
+}
+
+func TestResponseCacheReplaysOnlyHandlerHeaders(t *testing.T) {
+	c := newResponseCache(time.Minute, 100)
+	var calls int
+	h := cachedStack(c, &calls)
+
+	first := httptest.NewRequest(http.MethodGet, "/books/dune/page/1", nil)
+	first.Header.Set("Origin", "https://a.example")
+	h.ServeHTTP(httptest.NewRecorder(), first)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/books/dune/page/1", nil))
+	if got := rec.Header().Get("X-Cache"); got != "HIT" {
+		t.Fatalf("X-Cache = %q, want HIT", got)
+	}
+	if got := rec.Header().Values("X-Request-ID"); len(got) != 1 || got[0] != "req-2" {
+		t.Errorf("X-Request-ID = %q, want only this request's req-2", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin = %q replayed for a request without Origin", got)
+	}
+	if got := rec.Header().Get("ETag"); got != `"v1"` {
+		t.Errorf("ETag = %q, want the handler's %q", got, `"v1"`)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "text/plain; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want the handler's", got)
+	}
+}
+
+func TestResponseCacheSkipsSetCookie(t *testing.T) {
+	c := newResponseCache(time.Minute, 100)
+	var calls int
+	h := c.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.SetCookie(w, &http.Cookie{Name: "s", Value: "1"})
+		w.Write([]byte("x"))
+	}))
+	for i := 0; i < 2; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/p", nil))
+	}
+	if calls != 2 {
+		t.Errorf("handler ran %d times, want responses with Set-Cookie never cached", calls)
+	}
+}
+
+// countingCache returns c's middleware around a handler that counts its
+// runs in calls.
+func countingCache(c *responseCache, calls *int) http.Handler {
+	return c.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		w.Write([]byte("page"))
+	}))
+}
+
+func TestResponseCacheIgnoresUnknownQueryParams(t *testing.T) {
+	c := newResponseCache(time.Minute, 100, "lang")
+	var calls int
+	h := countingCache(c, &calls)
+
+	for i := 0; i < 50; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/books/dune/page/1?x="+strconv.Itoa(i), nil))
+	}
+	if n := len(c.entries); n != 0 {
+		t.Errorf("cache holds %d entries for unknown query parameters, want none", n)
+	}
+
+	// Allowed parameters are part of the key, in canonical order.
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/books/dune/page/1?lang=en", nil))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/books/dune/page/1?lang=en", nil))
+	if rec.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("second ?lang=en request X-Cache = %q, want HIT", rec.Header().Get("X-Cache"))
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/books/dune/page/1?lang=fr", nil))
+	if rec.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("?lang=fr X-Cache = %q, want a separate entry", rec.Header().Get("X-Cache"))
+	}
+}
+
+func TestResponseCacheEvictsOldest(t *testing.T) {
+	const size = 3
+	c := newResponseCache(time.Minute, size)
+	var calls int
+	h := countingCache(c, &calls)
+
+	for i := 0; i < 10; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/books/"+strconv.Itoa(i)+"/page/1", nil))
+	}
+	if n := len(c.entries); n != size {
+		t.Fatalf("cache holds %d entries, want %d", n, size)
+	}
+	for i, want := range map[int]bool{0: false, 6: false, 7: true, 9: true} {
+		if _, ok := c.entries["/books/"+strconv.Itoa(i)+"/page/1"]; ok != want {
+			t.Errorf("book %d cached = %v, want %v", i, ok, want)
+		}
+	}
+}
+
+func TestResponseCacheRefreshKeepsEntry(t *testing.T) {
+	c := newResponseCache(time.Minute, 2)
+	old := cachedResponse{status: http.StatusOK, expires: time.Now().Add(-time.Second)}
+	c.put("/a", old)
+	delete(c.entries, "/a") // as get does once it has expired
+	c.put("/a", cachedResponse{status: http.StatusOK, expires: time.Now().Add(time.Minute)})
+	// Reusing the first slot belongs to the expired response, not the
+	// fresh one stored under the same key.
+	c.put("/b", cachedResponse{status: http.StatusOK, expires: time.Now().Add(time.Minute)})
+	if _, ok := c.entries["/a"]; !ok {
+		t.Error("storing /b dropped the fresh /a")
+	}
+}
+
+func TestResponseCacheSweep(t *testing.T) {
+	c := newResponseCache(time.Minute, 10)
+	now := time.Now()
+	c.put("/old", cachedResponse{expires: now.Add(-time.Second)})
+	c.put("/new", cachedResponse{expires: now.Add(time.Minute)})
+
+	c.sweep(now)
+	if _, ok := c.entries["/old"]; ok {
+		t.Error("sweep kept an expired response")
+	}
+	if _, ok := c.entries["/new"]; !ok {
+		t.Error("sweep dropped a live response")
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+
+	"golang/web"
+)
+
+var errBadEscape = errors.New("invalid percent-encoding")
+
+// canonicalPaths makes every request use one spelling of its path before
+// it reaches the router, so a route cannot be bypassed with an alternative
+// encoding. Escapes of unreserved characters and of "/" are decoded, other
+// escapes are upper-cased, repeated slashes collapse and dot segments are
+// resolved.
+//
+// A GET or HEAD for a non-canonical path is redirected with 301. Other
+// methods are rejected with 400, or redirected with 308 (which keeps method
+// and body) when redirectWrites is set.
+func canonicalPaths(redirectWrites bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			escaped := r.URL.EscapedPath()
+			canonical, err := canonicalPath(escaped)
+			if err != nil {
+				web.WriteError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if canonical == escaped {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			target := canonical
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			switch {
+			case r.Method == http.MethodGet || r.Method == http.MethodHead:
+				http.Redirect(w, r, target, http.StatusMovedPermanently)
+			case redirectWrites:
+				http.Redirect(w, r, target, http.StatusPermanentRedirect)
+			default:
+				web.WriteError(w, http.StatusBadRequest, "non-canonical request path, use "+canonical)
+			}
+		})
+	}
+}
+
+// canonicalPath returns the canonical form of an escaped URL path.
+func canonicalPath(escaped string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(escaped); i++ {
+		c := escaped[i]
+		if c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		if i+2 >= len(escaped) || !isHex(escaped[i+1]) || !isHex(escaped[i+2]) {
+			return "", errBadEscape
+		}
+		d := unhex(escaped[i+1])<<4 | unhex(escaped[i+2])
+		if isUnreserved(d) || d == '/' {
+			b.WriteByte(d)
+		} else {
+			b.WriteString(strings.ToUpper(escaped[i : i+3]))
+		}
+		i += 2
+	}
+
+	p := b.String()
+	if p == "" {
+		return "/", nil
+	}
+	cleaned := path.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned, nil
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
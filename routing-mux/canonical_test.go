@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCanonicalPath(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"/users/42", "/users/42"},
+		{"/users%2F42", "/users/42"},
+		{"//users///42", "/users/42"},
+		{"/admin%2f%2Flatency", "/admin/latency"},
+		{"/b%6Foks/", "/books/"},
+		{"/books/a%2fb/../c", "/books/a/c"},
+		{"/books/a%3fb", "/books/a%3Fb"},
+		{"", "/"},
+	}
+	for _, tt := range tests {
+		got, err := canonicalPath(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("canonicalPath(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"/books/%zz", "/books/%4"} {
+		if _, err := canonicalPath(bad); err == nil {
+			t.Errorf("canonicalPath(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestCanonicalPathsMiddleware(t *testing.T) {
+	reached := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	tests := []struct {
+		name           string
+		method, target string
+		redirectWrites bool
+		wantStatus     int
+		wantLocation   string
+	}{
+		{"canonical", http.MethodGet, "/users/42", false, http.StatusOK, ""},
+		{"encoded slash", http.MethodGet, "/users%2F42?full=1", false, http.StatusMovedPermanently, "/users/42?full=1"},
+		{"duplicate slashes", http.MethodGet, "//users//42", false, http.StatusMovedPermanently, "/users/42"},
+		{"write rejected", http.MethodPost, "//users", false, http.StatusBadRequest, ""},
+		{"write redirected", http.MethodPost, "//users", true, http.StatusPermanentRedirect, "/users"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			canonicalPaths(tt.redirectWrites)(reached).ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if loc := rec.Header().Get("Location"); loc != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", loc, tt.wantLocation)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import "net/http"
+
+// Chain is a list of middleware applied as one. The first middleware is
+// the outermost: it sees the request first and the response last.
+type Chain struct {
+	middleware []func(http.Handler) http.Handler
+}
+
+// NewChain returns a Chain of mw, outermost first.
+func NewChain(mw ...func(http.Handler) http.Handler) Chain {
+	return Chain{middleware: append([]func(http.Handler) http.Handler(nil), mw...)}
+}
+
+// Then wraps h in the chain's middleware.
+func (c Chain) Then(h http.Handler) http.Handler {
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		h = c.middleware[i](h)
+	}
+	return h
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	database "golang/MySQL-Database"
+)
+
+// dbChecker is what checkDB needs from *sql.DB.
+type dbChecker interface {
+	database.Pinger
+	database.Querier
+}
+
+// checkDB verifies that db is reachable and answers queries: a ping, a
+// SELECT 1 and the server version, which it reports to out. It is what
+// -check-db runs in place of the server, so deploy pipelines can test a
+// DSN before rolling out.
+func checkDB(ctx context.Context, db dbChecker, out io.Writer) error {
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("ping: %w", err)
+	}
+	var one int
+	if err := db.QueryRowContext(ctx, `SELECT 1`).Scan(&one); err != nil {
+		return fmt.Errorf("select 1: %w", err)
+	}
+	version, err := database.ServerVersion(ctx, db)
+	if err != nil {
+		return fmt.Errorf("server version: %w", err)
+	}
+	fmt.Fprintf(out, "MySQL %s: ok\n", version)
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCheckDBSucceeds(t *testing.T) {
+	db, mock := newPingMock(t)
+	mock.ExpectPing()
+	mock.ExpectQuery(`SELECT 1`).WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+	mock.ExpectQuery(`SELECT VERSION\(\)`).WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow("8.0.36"))
+
+	var out bytes.Buffer
+	if err := checkDB(context.Background(), db, &out); err != nil {
+		t.Fatalf("checkDB = %v, want nil", err)
+	}
+	if out.String() != "MySQL 8.0.36: ok\n" {
+		t.Errorf("output = %q, want the server version", out.String())
+	}
+}
+
+func TestCheckDBFails(t *testing.T) {
+	down := errors.New("connection refused")
+	tests := []struct {
+		name   string
+		expect func(sqlmock.Sqlmock)
+		want   string
+	}{
+		{"ping", func(m sqlmock.Sqlmock) { m.ExpectPing().WillReturnError(down) }, "ping: "},
+		{"select", func(m sqlmock.Sqlmock) {
+			m.ExpectPing()
+			m.ExpectQuery(`SELECT 1`).WillReturnError(down)
+		}, "select 1: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newPingMock(t)
+			tt.expect(mock)
+
+			var out bytes.Buffer
+			err := checkDB(context.Background(), db, &out)
+			if !errors.Is(err, down) || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("checkDB = %v, want %q wrapping the driver error", err, tt.want)
+			}
+			if out.Len() != 0 {
+				t.Errorf("output = %q, want nothing on failure", out.String())
+			}
+		})
+	}
+}
+
+func TestRunCheckDBExitStatus(t *testing.T) {
+	// Nothing listens on port 1, so the ping is refused at once.
+	cfg := config{DSN: "root:root@tcp(127.0.0.1:1)/root?timeout=1s"}
+	if code := runCheckDB(cfg); code != 1 {
+		t.Errorf("unreachable server: exit status %d, want 1", code)
+	}
+	if code := runCheckDB(config{DSN: "not a dsn"}); code != 1 {
+		t.Errorf("malformed DSN: exit status %d, want 1", code)
+	}
+}
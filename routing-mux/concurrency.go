@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+
+	"golang/web"
+)
+
+// concurrencyLimiter caps the requests one client IP may have in flight
+// at once, so a single client can't tie up every worker with slow
+// requests while staying under the rate limit.
+type concurrencyLimiter struct {
+	max            int
+	trustForwarded bool
+
+	mu       sync.Mutex
+	inFlight map[string]int
+}
+
+func newConcurrencyLimiter(max int, trustForwarded bool) *concurrencyLimiter {
+	return &concurrencyLimiter{max: max, trustForwarded: trustForwarded, inFlight: make(map[string]int)}
+}
+
+func (cl *concurrencyLimiter) acquire(ip string) bool {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if cl.inFlight[ip] >= cl.max {
+		return false
+	}
+	cl.inFlight[ip]++
+	return true
+}
+
+// release drops idle IPs from the map, which therefore only ever holds
+// clients with requests in progress.
+func (cl *concurrencyLimiter) release(ip string) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if cl.inFlight[ip]--; cl.inFlight[ip] <= 0 {
+		delete(cl.inFlight, ip)
+	}
+}
+
+// middleware answers 429 while the client already has max requests in
+// flight. The slot is given back when the handler returns or panics.
+func (cl *concurrencyLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := clientIP(r, cl.trustForwarded)
+		if !cl.acquire(ip) {
+			w.Header().Set("Retry-After", "1")
+			web.WriteError(w, http.StatusTooManyRequests, "too many concurrent requests")
+			return
+		}
+		defer cl.release(ip)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// blockingLimiter returns cl's middleware around a handler that signals
+// entered and then waits for release to be closed.
+func blockingLimiter(cl *concurrencyLimiter, entered chan<- struct{}, release <-chan struct{}) http.Handler {
+	return cl.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	}))
+}
+
+func requestFrom(ip string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = ip + ":1234"
+	return req
+}
+
+func TestConcurrencyLimiterCapsOneIP(t *testing.T) {
+	const limit = 3
+	cl := newConcurrencyLimiter(limit, false)
+	entered, release := make(chan struct{}), make(chan struct{})
+	h := blockingLimiter(cl, entered, release)
+
+	var wg sync.WaitGroup
+	codes := make(chan int, limit+1)
+	serve := func(ip string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, requestFrom(ip))
+			codes <- rec.Code
+		}()
+	}
+	for i := 0; i < limit; i++ {
+		serve("192.0.2.1")
+		<-entered
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, requestFrom("192.0.2.1"))
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("request %d from one IP = %d, want 429 with Retry-After", limit+1, rec.Code)
+	}
+
+	// Another client still gets a slot while the first is at its cap.
+	serve("192.0.2.2")
+	<-entered
+
+	close(release)
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("in-flight request = %d, want 200", code)
+		}
+	}
+
+	// Every slot is back, so the first client can be served again.
+	rec = httptest.NewRecorder()
+	cl.middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(rec, requestFrom("192.0.2.1"))
+	if rec.Code != http.StatusOK {
+		t.Errorf("after release = %d, want 200", rec.Code)
+	}
+}
+
+func TestConcurrencyLimiterReleasesOnPanic(t *testing.T) {
+	cl := newConcurrencyLimiter(1, false)
+	h := cl.middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("handler bug")
+	}))
+
+	func() {
+		defer func() { recover() }()
+		h.ServeHTTP(httptest.NewRecorder(), requestFrom("192.0.2.1"))
+	}()
+
+	cl.mu.Lock()
+	n := len(cl.inFlight)
+	cl.mu.Unlock()
+	if n != 0 {
+		t.Errorf("%d IPs still hold slots after a panic, want none", n)
+	}
+}
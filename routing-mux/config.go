@@ -0,0 +1,415 @@
+package main
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	database "golang/MySQL-Database"
+	"golang/web"
+)
+
+// config is read from the environment at startup. DSN, AdminUsers and
+// SessionKey are secrets and are never reported by /debug/config.
+type config struct {
+	Addr        string
+	DSN         string
+	MySQLCACert string
+	// AdminUsers maps usernames to bcrypt password hashes for the admin
+	// routes: /admin/, /debug/config, /export/ and /jobs/.
+	// ADMIN_USERS lists them as "name:hash,...".
+	AdminUsers map[string]string
+	// SessionKey signs session cookies. When empty a random key is made at
+	// startup, so sessions don't survive a restart.
+	SessionKey string
+	SessionTTL time.Duration
+	// SessionStore is "cookie" for signed cookie sessions or "mysql" for
+	// revocable sessions in the sessions table.
+	SessionStore string
+
+	// Timeouts default to apiTimeouts.
+	Timeouts web.Timeouts
+
+	Pool     database.PoolConfig
+	PoolWait time.Duration
+	// At startup MySQL is pinged up to ConnectAttempts times, waiting
+	// ConnectBackoff after the first failure and twice as long after each
+	// further one.
+	ConnectAttempts int
+	ConnectBackoff  time.Duration
+	// SQLMode is set as sql_mode on every connection; "" keeps the
+	// server's default.
+	SQLMode string
+	// SecondaryDSN, when set, takes writes while the primary fails
+	// FailoverThreshold health checks in a row, checked every FailoverEvery.
+	SecondaryDSN      string
+	FailoverEvery     time.Duration
+	FailoverThreshold int
+
+	MaxCookies      int
+	MaxPathSegments int
+	// MaxHeaderBytes caps the request line and headers; larger requests
+	// get a JSON 431.
+	MaxHeaderBytes int
+	// RedirectNonCanonicalWrites answers writes to a non-canonical path
+	// with 308 instead of 400.
+	RedirectNonCanonicalWrites bool
+	// SanitizeUTF8 replaces invalid UTF-8 in JSON request bodies with
+	// U+FFFD instead of rejecting them with 400.
+	SanitizeUTF8 bool
+
+	UploadDir            string
+	UploadAllowedTypes   map[string]bool
+	UploadMaxParts       int
+	UploadMaxBytes       int64
+	UploadProgressEvery  time.Duration
+	UploadMinBytesPerSec int64
+	UploadSlowFor        time.Duration
+
+	// ExportDir holds finished exports until their job expires.
+	ExportDir string
+
+	PasswordMinLength     int
+	PasswordRequireSymbol bool
+
+	// Requests to these paths or from these User-Agents are health checks
+	// and are left out of the request logs unless LogHealthChecks is set.
+	HealthCheckPaths  []string
+	HealthCheckAgents []string
+	LogHealthChecks   bool
+
+	// LogBodyBytes is how much of each request body is logged; 0 disables it.
+	LogBodyBytes int
+
+	CacheTTL time.Duration
+	// CacheMaxEntries caps how many responses the cache holds.
+	CacheMaxEntries int
+	// CacheWarmPaths are requested once at startup to fill the cache.
+	CacheWarmPaths []string
+
+	// Each client IP may make RateLimitPerSec requests per second, with
+	// bursts of up to RateLimitBurst; 0 disables the limit.
+	// TrustForwardedFor takes the IP from X-Forwarded-For.
+	RateLimitPerSec   int
+	RateLimitBurst    int
+	TrustForwardedFor bool
+	// MaxConcurrentPerIP caps the requests one client IP may have in
+	// flight; 0 disables the cap.
+	MaxConcurrentPerIP int
+	// Requests are shed with 503 while the p99 latency over a ShedWindow
+	// is above ShedP99Threshold; 0 disables shedding.
+	ShedP99Threshold time.Duration
+	ShedWindow       time.Duration
+
+	// Browsers on CORSOrigins may call the API with CORSMethods and
+	// CORSHeaders.
+	CORSOrigins []string
+	CORSMethods []string
+	CORSHeaders []string
+
+	// UserMetadataKeys are the metadata fields GET /users may filter on
+	// with ?meta.<key>=<value>; with none, no filter is accepted.
+	UserMetadataKeys map[string]bool
+
+	// Features holds the flags listed in FEATURES, e.g. "a,b".
+	Features map[string]bool
+}
+
+// apiTimeouts are the server defaults. ReadHeader keeps slowloris clients
+// from holding connections. Read bounds a whole request, uploads included,
+// so it is well past UploadSlowFor: a stalled upload is aborted by the
+// upload handler, which also logs progress every UploadProgressEvery, long
+// before the server would cut it off. Write bounds a whole response, such
+// as streaming GET /export/full or downloading a finished export from
+// /jobs/{id}/result.
+var apiTimeouts = web.Timeouts{
+	ReadHeader: web.DefaultTimeouts.ReadHeader,
+	Read:       5 * time.Minute,
+	Write:      5 * time.Minute,
+	Idle:       web.DefaultTimeouts.Idle,
+}
+
+func loadConfig() config {
+	return config{
+		Addr:        envString("ADDR", ":80"),
+		DSN:         database.DSNFromEnv(),
+		MySQLCACert: envString("MYSQL_CA_CERT", ""),
+		AdminUsers:  envPairs("ADMIN_USERS"),
+		SessionKey:  envString("SESSION_KEY", ""),
+		SessionTTL:  envDuration("SESSION_TTL", 24*time.Hour),
+
+		SessionStore: envString("SESSION_STORE", "cookie"),
+
+		Timeouts: web.Timeouts{
+			ReadHeader: envDuration("READ_HEADER_TIMEOUT", apiTimeouts.ReadHeader),
+			Read:       envDuration("READ_TIMEOUT", apiTimeouts.Read),
+			Write:      envDuration("WRITE_TIMEOUT", apiTimeouts.Write),
+			Idle:       envDuration("IDLE_TIMEOUT", apiTimeouts.Idle),
+		},
+
+		Pool: database.PoolConfig{
+			MaxOpen:     envInt("MYSQL_MAX_OPEN_CONNS", database.DefaultMaxOpenConns),
+			MaxIdle:     envInt("MYSQL_MAX_IDLE_CONNS", database.DefaultMaxIdleConns),
+			MaxLifetime: envDuration("MYSQL_CONN_MAX_LIFETIME", database.DefaultConnMaxLifetime),
+			MaxIdleTime: envDuration("MYSQL_CONN_MAX_IDLE_TIME", database.DefaultConnMaxIdleTime),
+		},
+		PoolWait: envDuration("MYSQL_POOL_WAIT", 100*time.Millisecond),
+		SQLMode:  envString("MYSQL_SQL_MODE", database.DefaultSQLMode),
+
+		SecondaryDSN:      envString("MYSQL_SECONDARY_DSN", ""),
+		FailoverEvery:     envDuration("MYSQL_FAILOVER_EVERY", 5*time.Second),
+		FailoverThreshold: envInt("MYSQL_FAILOVER_THRESHOLD", 3),
+
+		ConnectAttempts: envInt("MYSQL_CONNECT_ATTEMPTS", 10),
+		ConnectBackoff:  envDuration("MYSQL_CONNECT_BACKOFF", 500*time.Millisecond),
+
+		MaxCookies:      envInt("MAX_COOKIES", 20),
+		MaxPathSegments: envInt("MAX_PATH_SEGMENTS", 16),
+		MaxHeaderBytes:  envInt("MAX_HEADER_BYTES", 16<<10),
+
+		RedirectNonCanonicalWrites: envBool("REDIRECT_NON_CANONICAL_WRITES", false),
+		SanitizeUTF8:               envBool("SANITIZE_UTF8", false),
+
+		UploadDir:            envString("UPLOAD_DIR", filepath.Join(os.TempDir(), "uploads")),
+		ExportDir:            envString("EXPORT_DIR", filepath.Join(os.TempDir(), "exports")),
+		UploadAllowedTypes:   envSet("UPLOAD_ALLOWED_TYPES", []string{"image/png", "image/jpeg", "image/gif", "image/webp", "application/pdf"}),
+		UploadMaxParts:       envInt("UPLOAD_MAX_PARTS", 20),
+		UploadMaxBytes:       int64(envInt("UPLOAD_MAX_BYTES", 32<<20)),
+		UploadProgressEvery:  envDuration("UPLOAD_PROGRESS_EVERY", 5*time.Second),
+		UploadMinBytesPerSec: int64(envInt("UPLOAD_MIN_BYTES_PER_SEC", 1024)),
+		UploadSlowFor:        envDuration("UPLOAD_SLOW_FOR", 10*time.Second),
+
+		PasswordMinLength:     envInt("PASSWORD_MIN_LENGTH", 12),
+		PasswordRequireSymbol: envBool("PASSWORD_REQUIRE_SYMBOL", false),
+
+		HealthCheckPaths:  envList("HEALTH_CHECK_PATHS", []string{"/healthz", "/livez", "/readyz"}),
+		HealthCheckAgents: envList("HEALTH_CHECK_AGENTS", []string{"kube-probe", "ELB-HealthChecker", "GoogleHC"}),
+		LogHealthChecks:   envBool("LOG_HEALTH_CHECKS", false),
+
+		LogBodyBytes: envInt("LOG_BODY_BYTES", 0),
+
+		CacheTTL:        envDuration("CACHE_TTL", time.Minute),
+		CacheMaxEntries: envInt("CACHE_MAX_ENTRIES", 1000),
+		CacheWarmPaths:  envList("CACHE_WARM_PATHS", nil),
+
+		RateLimitPerSec:   envInt("RATE_LIMIT_PER_SEC", 10),
+		RateLimitBurst:    envInt("RATE_LIMIT_BURST", 20),
+		TrustForwardedFor: envBool("TRUST_X_FORWARDED_FOR", false),
+
+		MaxConcurrentPerIP: envInt("MAX_CONCURRENT_PER_IP", 10),
+		ShedP99Threshold:   envDuration("SHED_P99_THRESHOLD", 0),
+		ShedWindow:         envDuration("SHED_WINDOW", 10*time.Second),
+
+		CORSOrigins: envList("CORS_ALLOWED_ORIGINS", nil),
+		CORSMethods: envList("CORS_ALLOWED_METHODS", []string{"GET", "POST", "PUT", "PATCH", "DELETE"}),
+		CORSHeaders: envList("CORS_ALLOWED_HEADERS", []string{"Content-Type", "Authorization", "X-Request-ID"}),
+
+		UserMetadataKeys: envSet("USER_METADATA_KEYS", nil),
+
+		Features: envSet("FEATURES", nil),
+	}
+}
+
+// publicConfig is the part of config that is safe to show to operators.
+type publicConfig struct {
+	Addr                 string          `json:"addr"`
+	DSN                  string          `json:"dsn"`
+	SecondaryDSN         string          `json:"secondary_dsn,omitempty"`
+	FailoverEvery        string          `json:"failover_every"`
+	FailoverThreshold    int             `json:"failover_threshold"`
+	MySQLTLS             bool            `json:"mysql_tls"`
+	SessionTTL           string          `json:"session_ttl"`
+	SessionStore         string          `json:"session_store"`
+	ReadHeaderTimeout    string          `json:"read_header_timeout"`
+	ReadTimeout          string          `json:"read_timeout"`
+	WriteTimeout         string          `json:"write_timeout"`
+	IdleTimeout          string          `json:"idle_timeout"`
+	MaxOpenConns         int             `json:"max_open_conns"`
+	MaxIdleConns         int             `json:"max_idle_conns"`
+	MaxLifetime          string          `json:"conn_max_lifetime"`
+	MaxIdleTime          string          `json:"conn_max_idle_time"`
+	PoolWait             string          `json:"pool_wait"`
+	SQLMode              string          `json:"sql_mode"`
+	ConnectAttempts      int             `json:"connect_attempts"`
+	ConnectBackoff       string          `json:"connect_backoff"`
+	MaxCookies           int             `json:"max_cookies"`
+	MaxPathSegments      int             `json:"max_path_segments"`
+	MaxHeaderBytes       int             `json:"max_header_bytes"`
+	SanitizeUTF8         bool            `json:"sanitize_utf8"`
+	UploadDir            string          `json:"upload_dir"`
+	ExportDir            string          `json:"export_dir"`
+	UploadAllowedTypes   map[string]bool `json:"upload_allowed_types"`
+	UploadMaxParts       int             `json:"upload_max_parts"`
+	UploadMaxBytes       int64           `json:"upload_max_bytes"`
+	UploadProgressEvery  string          `json:"upload_progress_every"`
+	UploadMinBytesPerSec int64           `json:"upload_min_bytes_per_sec"`
+	UploadSlowFor        string          `json:"upload_slow_for"`
+	PasswordPolicy       passwordPolicy  `json:"password_policy"`
+	LogBodyBytes         int             `json:"log_body_bytes"`
+	CacheTTL             string          `json:"cache_ttl"`
+	CacheMaxEntries      int             `json:"cache_max_entries"`
+	CacheWarmPaths       []string        `json:"cache_warm_paths"`
+	RateLimitPerSec      int             `json:"rate_limit_per_sec"`
+	RateLimitBurst       int             `json:"rate_limit_burst"`
+	TrustForwardedFor    bool            `json:"trust_x_forwarded_for"`
+	MaxConcurrentPerIP   int             `json:"max_concurrent_per_ip"`
+	ShedP99Threshold     string          `json:"shed_p99_threshold"`
+	ShedWindow           string          `json:"shed_window"`
+	CORSOrigins          []string        `json:"cors_allowed_origins"`
+	CORSMethods          []string        `json:"cors_allowed_methods"`
+	CORSHeaders          []string        `json:"cors_allowed_headers"`
+	UserMetadataKeys     map[string]bool `json:"user_metadata_keys"`
+	Features             map[string]bool `json:"features"`
+}
+
+// redactDSN is database.RedactDSN, except that an unset DSN stays empty
+// instead of turning into the driver's default address.
+func redactDSN(dsn string) string {
+	if dsn == "" {
+		return ""
+	}
+	return database.RedactDSN(dsn)
+}
+
+func (c config) public() publicConfig {
+	return publicConfig{
+		Addr:                 c.Addr,
+		DSN:                  database.RedactDSN(c.DSN),
+		SecondaryDSN:         redactDSN(c.SecondaryDSN),
+		FailoverEvery:        c.FailoverEvery.String(),
+		FailoverThreshold:    c.FailoverThreshold,
+		MySQLTLS:             c.MySQLCACert != "",
+		SessionTTL:           c.SessionTTL.String(),
+		SessionStore:         c.SessionStore,
+		ReadHeaderTimeout:    c.Timeouts.ReadHeader.String(),
+		ReadTimeout:          c.Timeouts.Read.String(),
+		WriteTimeout:         c.Timeouts.Write.String(),
+		IdleTimeout:          c.Timeouts.Idle.String(),
+		MaxOpenConns:         c.Pool.MaxOpen,
+		MaxIdleConns:         c.Pool.MaxIdle,
+		MaxLifetime:          c.Pool.MaxLifetime.String(),
+		MaxIdleTime:          c.Pool.MaxIdleTime.String(),
+		PoolWait:             c.PoolWait.String(),
+		SQLMode:              c.SQLMode,
+		ConnectAttempts:      c.ConnectAttempts,
+		ConnectBackoff:       c.ConnectBackoff.String(),
+		MaxCookies:           c.MaxCookies,
+		MaxPathSegments:      c.MaxPathSegments,
+		MaxHeaderBytes:       c.MaxHeaderBytes,
+		SanitizeUTF8:         c.SanitizeUTF8,
+		UploadDir:            c.UploadDir,
+		ExportDir:            c.ExportDir,
+		UploadAllowedTypes:   c.UploadAllowedTypes,
+		UploadMaxParts:       c.UploadMaxParts,
+		UploadMaxBytes:       c.UploadMaxBytes,
+		UploadProgressEvery:  c.UploadProgressEvery.String(),
+		UploadMinBytesPerSec: c.UploadMinBytesPerSec,
+		UploadSlowFor:        c.UploadSlowFor.String(),
+		PasswordPolicy:       c.passwordPolicy(),
+		LogBodyBytes:         c.LogBodyBytes,
+		CacheTTL:             c.CacheTTL.String(),
+		CacheMaxEntries:      c.CacheMaxEntries,
+		CacheWarmPaths:       c.CacheWarmPaths,
+		RateLimitPerSec:      c.RateLimitPerSec,
+		RateLimitBurst:       c.RateLimitBurst,
+		TrustForwardedFor:    c.TrustForwardedFor,
+		MaxConcurrentPerIP:   c.MaxConcurrentPerIP,
+		ShedP99Threshold:     c.ShedP99Threshold.String(),
+		ShedWindow:           c.ShedWindow.String(),
+		CORSOrigins:          c.CORSOrigins,
+		CORSMethods:          c.CORSMethods,
+		CORSHeaders:          c.CORSHeaders,
+		UserMetadataKeys:     c.UserMetadataKeys,
+		Features:             c.Features,
+	}
+}
+
+func (c config) passwordPolicy() passwordPolicy {
+	return passwordPolicy{
+		MinLength:     c.PasswordMinLength,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: c.PasswordRequireSymbol,
+		Blacklist:     commonPasswords,
+	}
+}
+
+func envString(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return def
+}
+
+func envInt(key string, def int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return n
+	}
+	return def
+}
+
+func envBool(key string, def bool) bool {
+	if b, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return b
+	}
+	return def
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return d
+	}
+	return def
+}
+
+// envList splits a comma separated variable, dropping empty items. def is
+// returned when the variable is unset.
+func envList(key string, def []string) []string {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+	var list []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// envPairs reads a list of "key:value" items. Items without a colon are
+// ignored.
+func envPairs(key string) map[string]string {
+	pairs := make(map[string]string)
+	for _, item := range envList(key, nil) {
+		if k, v, ok := strings.Cut(item, ":"); ok {
+			pairs[k] = v
+		}
+	}
+	return pairs
+}
+
+func envSet(key string, def []string) map[string]bool {
+	set := make(map[string]bool)
+	for _, name := range envList(key, def) {
+		set[name] = true
+	}
+	return set
+}
+
+// open opens dsn with TLS applied when a CA is configured and with the
+// configured sql_mode. It does not connect; see database.Open.
+func (c config) open(dsn string) (*sql.DB, error) {
+	if c.MySQLCACert != "" {
+		var err error
+		if dsn, err = database.DSNWithTLS(dsn); err != nil {
+			return nil, err
+		}
+	}
+	return database.Open(dsn, c.SQLMode)
+}
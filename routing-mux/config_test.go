@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDebugConfigReportsValuesWithoutSecrets(t *testing.T) {
+	t.Setenv("MYSQL_DSN", "app:hunter2@tcp(db:3306)/app?parseTime=true")
+	t.Setenv("ADMIN_USERS", "root:$2a$10$abcdefghijklmnopqrstuv")
+	t.Setenv("SESSION_KEY", "sessionkeysecret")
+	t.Setenv("READ_TIMEOUT", "7s")
+	t.Setenv("MYSQL_MAX_OPEN_CONNS", "4")
+	t.Setenv("RATE_LIMIT_PER_SEC", "3")
+	t.Setenv("FEATURES", "debug-headers")
+
+	rec := httptest.NewRecorder()
+	debugConfig(loadConfig()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/config", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	body := rec.Body.String()
+	for _, secret := range []string{"hunter2", "sessionkeysecret", "abcdefghijklmnopqrstuv"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("/debug/config leaks %q: %s", secret, body)
+		}
+	}
+
+	var got struct {
+		DSN             string          `json:"dsn"`
+		ReadTimeout     string          `json:"read_timeout"`
+		MaxOpenConns    int             `json:"max_open_conns"`
+		RateLimitPerSec int             `json:"rate_limit_per_sec"`
+		Features        map[string]bool `json:"features"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ReadTimeout != "7s" || got.MaxOpenConns != 4 || got.RateLimitPerSec != 3 || !got.Features["debug-headers"] {
+		t.Errorf("config = %+v, want the values from the environment", got)
+	}
+	if !strings.Contains(got.DSN, "db:3306") {
+		t.Errorf("dsn = %q, want the host kept", got.DSN)
+	}
+}
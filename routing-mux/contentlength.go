@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"golang/web"
+)
+
+// checkContentLength makes a request body that ends before its declared
+// Content-Length fail with an error wrapping web.ErrShortBody, instead of
+// the bare io.ErrUnexpectedEOF net/http reports, so handlers can tell a
+// truncated upload apart and answer 400. Each mismatch is logged. Chunked
+// bodies carry no length and are left alone.
+func checkContentLength(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength <= 0 || r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body := &lengthCheckedBody{ReadCloser: r.Body, declared: r.ContentLength, remaining: r.ContentLength}
+		r.Body = body
+		next.ServeHTTP(w, r)
+		if body.short {
+			log.Printf("request_id=%s %s %s: body ended %d bytes short of Content-Length %d",
+				RequestIDFromContext(r.Context()), r.Method, r.URL.Path, body.remaining, body.declared)
+		}
+	})
+}
+
+type lengthCheckedBody struct {
+	io.ReadCloser
+	declared, remaining int64
+	short               bool
+}
+
+func (b *lengthCheckedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining > 0 && (err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF)) {
+		b.short = true
+		err = fmt.Errorf("%w: %d of %d bytes missing", web.ErrShortBody, b.remaining, b.declared)
+	}
+	return n, err
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang/web"
+)
+
+// readBody serves r through checkContentLength and returns what the
+// handler read and the read error.
+func readBody(r *http.Request) (string, error) {
+	var body []byte
+	var err error
+	checkContentLength(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err = io.ReadAll(r.Body)
+	})).ServeHTTP(httptest.NewRecorder(), r)
+	return string(body), err
+}
+
+func TestCheckContentLengthShortBody(t *testing.T) {
+	logs := captureLog(t)
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"a":1}`))
+	req.ContentLength = 20
+
+	if _, err := readBody(req); !errors.Is(err, web.ErrShortBody) {
+		t.Fatalf("read error = %v, want ErrShortBody", err)
+	}
+	if !strings.Contains(logs.String(), "13 bytes short of Content-Length 20") {
+		t.Errorf("log = %q, want the mismatch", logs)
+	}
+}
+
+func TestCheckContentLengthShortJSONIs400(t *testing.T) {
+	captureLog(t)
+	// A complete value, so only the length check makes it fail.
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"username":"alice"}`))
+	req.ContentLength = 40
+	rec := httptest.NewRecorder()
+	checkContentLength(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var v map[string]string
+		if err := web.DecodeJSON(r, &v); err != nil {
+			web.WriteError(w, web.DecodeStatus(err), err.Error())
+		}
+	})).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "shorter than Content-Length") {
+		t.Errorf("got %d %s, want 400 for the short body", rec.Code, rec.Body)
+	}
+}
+
+func TestCheckContentLengthPassesCompleteBodies(t *testing.T) {
+	logs := captureLog(t)
+	full := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello"))
+	chunked := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello"))
+	chunked.ContentLength = -1
+
+	for name, req := range map[string]*http.Request{"full": full, "chunked": chunked} {
+		if body, err := readBody(req); err != nil || body != "hello" {
+			t.Errorf("%s: read %q, %v; want hello", name, body, err)
+		}
+	}
+	if logs.Len() != 0 {
+		t.Errorf("log = %q, want nothing", logs)
+	}
+}
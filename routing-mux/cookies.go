@@ -0,0 +1,80 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strings"
+)
+
+// dedupeCookies cleans up the Set-Cookie headers of every response before
+// they are sent: when a cookie name is set more than once only the last
+// value is kept, and no more than limit cookies go out. Hitting the limit
+// is logged, since it usually means a handler is setting cookies in a loop.
+func dedupeCookies(limit int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cw := &cookieWriter{ResponseWriter: w, limit: limit, path: r.URL.Path}
+			next.ServeHTTP(cw, r)
+			cw.fixCookies()
+		})
+	}
+}
+
+type cookieWriter struct {
+	http.ResponseWriter
+	limit int
+	path  string
+	fixed bool
+}
+
+func (cw *cookieWriter) WriteHeader(code int) {
+	cw.fixCookies()
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *cookieWriter) Write(b []byte) (int, error) {
+	cw.fixCookies()
+	return cw.ResponseWriter.Write(b)
+}
+
+func (cw *cookieWriter) Flush() {
+	cw.fixCookies()
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// fixCookies rewrites Set-Cookie once, right before the headers are sent.
+func (cw *cookieWriter) fixCookies() {
+	if cw.fixed {
+		return
+	}
+	cw.fixed = true
+
+	h := cw.Header()
+	values := h["Set-Cookie"]
+	if len(values) == 0 {
+		return
+	}
+
+	last := make(map[string]int, len(values))
+	for i, v := range values {
+		last[cookieName(v)] = i
+	}
+	kept := make([]string, 0, len(last))
+	for i, v := range values {
+		if last[cookieName(v)] == i {
+			kept = append(kept, v)
+		}
+	}
+	if cw.limit > 0 && len(kept) > cw.limit {
+		log.Printf("warning: %s set %d cookies, dropping all but the first %d", cw.path, len(kept), cw.limit)
+		kept = kept[:cw.limit]
+	}
+	h["Set-Cookie"] = kept
+}
+
+func cookieName(setCookie string) string {
+	name, _, _ := strings.Cut(setCookie, "=")
+	return strings.TrimSpace(name)
+}
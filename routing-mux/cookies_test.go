@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestDedupeCookiesKeepsLast(t *testing.T) {
+	h := dedupeCookies(20)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "old"})
+		http.SetCookie(w, &http.Cookie{Name: "theme", Value: "dark"})
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "new"})
+		w.Write([]byte("ok"))
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	want := []string{"theme=dark", "session=new"}
+	if got := rec.Result().Header["Set-Cookie"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Set-Cookie = %q, want %q", got, want)
+	}
+}
+
+func TestDedupeCookiesCapsCount(t *testing.T) {
+	logs := captureLog(t)
+	h := dedupeCookies(3)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 5; i++ {
+			http.SetCookie(w, &http.Cookie{Name: "c" + strconv.Itoa(i), Value: "v"})
+		}
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/loop", nil))
+
+	want := []string{"c0=v", "c1=v", "c2=v"}
+	if got := rec.Result().Header["Set-Cookie"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Set-Cookie = %q, want %q", got, want)
+	}
+	if logs.Len() == 0 {
+		t.Error("hitting the cap was not logged")
+	}
+}
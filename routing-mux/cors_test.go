@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func corsHandler() http.Handler {
+	return CORSMiddleware(
+		[]string{"https://app.example.com"},
+		[]string{"GET", "POST", "PUT", "DELETE"},
+		[]string{"Content-Type", "X-Request-ID"},
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("handled"))
+	}))
+}
+
+func preflight(origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodOptions, "/books/dune", nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", "PUT")
+	rec := httptest.NewRecorder()
+	corsHandler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCORSPreflightAllowedOrigin(t *testing.T) {
+	rec := preflight("https://app.example.com")
+	if rec.Code != http.StatusNoContent || rec.Body.Len() != 0 {
+		t.Fatalf("preflight = %d %q, want an empty 204", rec.Code, rec.Body)
+	}
+	want := map[string]string{
+		"Access-Control-Allow-Origin":  "https://app.example.com",
+		"Access-Control-Allow-Methods": "GET, POST, PUT, DELETE",
+		"Access-Control-Allow-Headers": "Content-Type, X-Request-ID",
+		"Access-Control-Max-Age":       "600",
+	}
+	for k, v := range want {
+		if got := rec.Header().Get(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+}
+
+func TestCORSDisallowedOrigin(t *testing.T) {
+	rec := preflight("https://evil.example.com")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("preflight = %d, want 204", rec.Code)
+	}
+	for _, k := range []string{"Access-Control-Allow-Origin", "Access-Control-Allow-Methods", "Access-Control-Allow-Headers"} {
+		if v := rec.Header().Get(k); v != "" {
+			t.Errorf("%s = %q for a disallowed origin", k, v)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/books/dune", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	get := httptest.NewRecorder()
+	corsHandler().ServeHTTP(get, req)
+	if v := get.Header().Get("Access-Control-Allow-Origin"); v != "" || get.Body.String() != "handled" {
+		t.Errorf("GET: Allow-Origin = %q, body %q; want no header and the handler's body", v, get.Body)
+	}
+}
+
+func TestCORSSimpleRequestAllowedOrigin(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/books/dune", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rec := httptest.NewRecorder()
+	corsHandler().ServeHTTP(rec, req)
+	if v := rec.Header().Get("Access-Control-Allow-Origin"); v != "https://app.example.com" || rec.Body.String() != "handled" {
+		t.Errorf("Allow-Origin = %q, body %q", v, rec.Body)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+)
+
+func TestCreateUserLocationResolvesToGet(t *testing.T) {
+	db, mock := newPingMock(t)
+	mock.ExpectExec("INSERT INTO users").WillReturnResult(sqlmock.NewResult(7, 1))
+	mock.ExpectQuery("FROM users WHERE id IN").WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(7, "carol", "hash", "carol@example.com", time.Now()))
+
+	r := mux.NewRouter()
+	users := &userHandler{db: db, router: r, passwords: passwordPolicy{MinLength: 1}}
+	ur := r.PathPrefix("/users").Subrouter()
+	ur.HandleFunc("", users.create).Methods(http.MethodPost)
+	ur.HandleFunc("/{id:[0-9]+}", users.get).Methods(http.MethodGet).Name("user")
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"username":"carol","password":"pw","email":"carol@example.com"}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /users = %d %s, want 201", rec.Code, rec.Body)
+	}
+	loc := rec.Header().Get("Location")
+	if loc != "/users/7" {
+		t.Errorf("Location = %q, want /users/7", loc)
+	}
+
+	get := httptest.NewRecorder()
+	r.ServeHTTP(get, httptest.NewRequest(http.MethodGet, loc, nil))
+	var u struct {
+		ID       int    `json:"id"`
+		Username string `json:"username"`
+	}
+	if err := json.Unmarshal(get.Body.Bytes(), &u); get.Code != http.StatusOK || err != nil || u.ID != 7 {
+		t.Errorf("GET %s = %d %s, want user 7", loc, get.Code, get.Body)
+	}
+}
+
+func TestCreateBookLocationResolvesToGet(t *testing.T) {
+	books := newBookStore()
+	r := mux.NewRouter()
+	r.HandleFunc("/books/{title}", books.createBook).Methods(http.MethodPost)
+	r.HandleFunc("/books/{title}", books.readBook).Methods(http.MethodGet)
+
+	req := httptest.NewRequest(http.MethodPost, "/books/the%20hobbit", strings.NewReader(`{"author":"Tolkien","pages":310}`))
+	req.Header.Set("Accept", "application/json")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST = %d %s, want 201", rec.Code, rec.Body)
+	}
+	loc := rec.Header().Get("Location")
+	if loc != "/books/the%20hobbit" {
+		t.Errorf("Location = %q, want /books/the%%20hobbit", loc)
+	}
+
+	get := httptest.NewRequest(http.MethodGet, loc, nil)
+	get.Header.Set("Accept", "application/json")
+	grec := httptest.NewRecorder()
+	r.ServeHTTP(grec, get)
+	var b book
+	if err := json.Unmarshal(grec.Body.Bytes(), &b); grec.Code != http.StatusOK || err != nil || b.Title != "the hobbit" {
+		t.Errorf("GET %s = %d %s, want the created book", loc, grec.Code, grec.Body)
+	}
+}
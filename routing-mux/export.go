@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	database "golang/MySQL-Database"
+	"golang/web"
+)
+
+// exportHandler serves full exports of every user with their comments,
+// either streamed straight to the client or as a background job for
+// exports that take longer than a client wants to hold a request open.
+type exportHandler struct {
+	db   database.Querier
+	jobs *jobStore
+	// dir holds the finished export files.
+	dir string
+}
+
+// stream writes the export as the join is read, one complete user at a
+// time. Once the first bytes are out the status can no longer change, so
+// a later database error ends the response early and the client sees
+// truncated JSON; an export that must be complete is better run as a job.
+func (h *exportHandler) stream(w http.ResponseWriter, r *http.Request) {
+	sw := &streamWriter{w: w}
+	if err := encodeExport(r.Context(), sw, h.db); err != nil {
+		log.Printf("request_id=%s export: %v", RequestIDFromContext(r.Context()), err)
+		if !sw.started {
+			web.WriteError(w, http.StatusInternalServerError, "could not export users")
+		}
+	}
+}
+
+// streamWriter sets the JSON content type on the first write, and
+// remembers whether it happened.
+type streamWriter struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	if !sw.started {
+		sw.w.Header().Set("Content-Type", "application/json")
+		sw.started = true
+	}
+	return sw.w.Write(p)
+}
+
+// create starts an export and answers 202 with the job. The client polls
+// the job's Location, GET /jobs/{id}, and downloads the JSON array from
+// its result URL once the job is done; DELETE /jobs/{id} cancels it.
+func (h *exportHandler) create(w http.ResponseWriter, r *http.Request) {
+	path := filepath.Join(h.dir, "export-"+newRequestID()+".json")
+	j := h.jobs.start(path, func(ctx context.Context) error {
+		return writeExport(ctx, h.db, path)
+	})
+	w.Header().Set("Location", "/jobs/"+j.ID)
+	web.WriteJSON(w, http.StatusAccepted, j.view())
+}
+
+// writeExport writes the export to path. It is written to a temporary
+// file first and renamed into place only once complete, so path never
+// holds a partial export; on error or cancellation nothing is left.
+func writeExport(ctx context.Context, db database.Querier, path string) (err error) {
+	part := path + ".part"
+	f, err := os.Create(part)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(part)
+		}
+	}()
+
+	bw := bufio.NewWriter(f)
+	if err = encodeExport(ctx, bw, db); err != nil {
+		return err
+	}
+	if err = bw.Flush(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(part, path)
+}
+
+// encodeExport writes every user that isn't deleted, with their comments,
+// to w as one JSON array, one user at a time as the join is read, so
+// memory stays bounded by the largest user. It stops with ctx.Err() once
+// ctx is done.
+func encodeExport(ctx context.Context, w io.Writer, db database.Querier) error {
+	enc := json.NewEncoder(w)
+	n := 0
+	err := database.EachUserWithComments(ctx, db, func(u database.UserComments) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		sep := ",\n"
+		if n == 0 {
+			sep = "[\n"
+		}
+		if _, err := io.WriteString(w, sep); err != nil {
+			return err
+		}
+		n++
+		return enc.Encode(u)
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		_, err = io.WriteString(w, "[]\n")
+	} else {
+		_, err = io.WriteString(w, "]\n")
+	}
+	return err
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+
+	database "golang/MySQL-Database"
+)
+
+// jobRouter mounts the export and job routes the way main does, without
+// the admin check.
+func jobRouter(exports *exportHandler) *mux.Router {
+	r := mux.NewRouter()
+	r.HandleFunc("/export/full", exports.stream).Methods(http.MethodGet)
+	r.HandleFunc("/export/jobs", exports.create).Methods(http.MethodPost)
+	r.HandleFunc("/jobs/{id}", exports.jobs.getHandler).Methods(http.MethodGet)
+	r.HandleFunc("/jobs/{id}", exports.jobs.cancelHandler).Methods(http.MethodDelete)
+	r.HandleFunc("/jobs/{id}/result", exports.jobs.resultHandler).Methods(http.MethodGet)
+	return r
+}
+
+// startExport posts to /export/jobs and returns the job it started.
+func startExport(t *testing.T, h http.Handler) jobView {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/export/jobs", nil))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("POST /export/jobs = %d %s, want 202", rec.Code, rec.Body)
+	}
+	var v jobView
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatal(err)
+	}
+	if got := rec.Header().Get("Location"); got != "/jobs/"+v.ID {
+		t.Errorf("Location = %q, want /jobs/%s", got, v.ID)
+	}
+	return v
+}
+
+// waitJob polls GET /jobs/{id} until the job has left the running state.
+func waitJob(t *testing.T, h http.Handler, id string) jobView {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/"+id, nil))
+		var v jobView
+		if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+			t.Fatal(err)
+		}
+		if v.State != jobRunning {
+			return v
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s still running", id)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// expectJoin seeds the join with alice's two comments, bob with none and
+// carol's one.
+func expectJoin(mock sqlmock.Sqlmock) {
+	now := time.Now()
+	mock.ExpectQuery(`FROM users u LEFT JOIN comments c`).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "username", "email", "created_at", "id", "body", "created_at"}).
+			AddRow(1, "alice", "", now, 10, "first", now).
+			AddRow(1, "alice", "", now, 11, "second", now).
+			AddRow(2, "bob", "", now, nil, nil, nil).
+			AddRow(3, "carol", "", now, 12, "hi", now))
+}
+
+// checkGrouped checks that body is the JSON array of the users seeded by
+// expectJoin, each with their own comments in order.
+func checkGrouped(t *testing.T, body []byte) {
+	t.Helper()
+	var users []database.UserComments
+	if err := json.Unmarshal(body, &users); err != nil {
+		t.Fatalf("export is not a JSON array: %v\n%s", err, body)
+	}
+	want := map[int][]int{1: {10, 11}, 2: {}, 3: {12}}
+	if len(users) != len(want) {
+		t.Fatalf("exported %d users, want %d", len(users), len(want))
+	}
+	for _, u := range users {
+		ids := want[u.ID]
+		if len(u.Comments) != len(ids) {
+			t.Errorf("user %d has %d comments, want %d", u.ID, len(u.Comments), len(ids))
+			continue
+		}
+		for i, c := range u.Comments {
+			if c.ID != ids[i] {
+				t.Errorf("user %d comment %d has id %d, want %d", u.ID, i, c.ID, ids[i])
+			}
+		}
+	}
+}
+
+func TestExportStreamGroupsCommentsUnderUsers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	expectJoin(mock)
+
+	h := jobRouter(&exportHandler{db: db, jobs: newJobStore(time.Hour), dir: t.TempDir()})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/export/full", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /export/full = %d %s, want 200", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	checkGrouped(t, rec.Body.Bytes())
+}
+
+func TestExportStreamQueryError(t *testing.T) {
+	captureLog(t)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.ExpectQuery(`FROM users u LEFT JOIN comments c`).WillReturnError(errors.New("connection refused"))
+
+	h := jobRouter(&exportHandler{db: db, jobs: newJobStore(time.Hour), dir: t.TempDir()})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/export/full", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("GET /export/full with a failing query = %d, want 500", rec.Code)
+	}
+}
+
+func TestExportJobGroupsCommentsUnderUsers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	expectJoin(mock)
+
+	dir := t.TempDir()
+	h := jobRouter(&exportHandler{db: db, jobs: newJobStore(time.Hour), dir: dir})
+
+	v := waitJob(t, h, startExport(t, h).ID)
+	if v.State != jobDone {
+		t.Fatalf("job state = %q (%s), want done", v.State, v.Error)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, v.Result, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s = %d, want 200", v.Result, rec.Code)
+	}
+
+	checkGrouped(t, rec.Body.Bytes())
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("export dir holds %d files, want just the finished export", len(entries))
+	}
+}
+
+func TestExportJobEmpty(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.ExpectQuery(`FROM users u LEFT JOIN comments c`).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "username", "email", "created_at", "id", "body", "created_at"}))
+
+	h := jobRouter(&exportHandler{db: db, jobs: newJobStore(time.Hour), dir: t.TempDir()})
+	v := waitJob(t, h, startExport(t, h).ID)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, v.Result, nil))
+	if got := rec.Body.String(); got != "[]\n" {
+		t.Errorf("empty export = %q, want an empty array", got)
+	}
+}
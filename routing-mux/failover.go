@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang/web"
+)
+
+// failover moves writes to a secondary database while the primary keeps
+// failing health checks. After threshold failed pings in a row the
+// secondary is promoted, provided it answers a ping itself. Once the
+// primary passes threshold pings in a row it takes the writes back.
+//
+// This is for HA demos: nothing replicates between the two, so rows
+// written during a failover stay on the secondary.
+type failover struct {
+	primary, secondary *sql.DB
+	threshold          int
+	timeout            time.Duration
+
+	checking    sync.Mutex
+	mu          sync.Mutex
+	onSecondary bool
+	// streak counts consecutive primary failures while on the primary and
+	// consecutive primary successes while on the secondary.
+	streak     int
+	lastErr    string
+	switchedAt time.Time
+}
+
+func newFailover(primary, secondary *sql.DB, threshold int, timeout time.Duration) *failover {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &failover{primary: primary, secondary: secondary, threshold: threshold, timeout: timeout}
+}
+
+// startOnSecondary promotes the secondary right away, for a primary that
+// was already down at startup.
+func (f *failover) startOnSecondary(err error) {
+	f.mu.Lock()
+	f.onSecondary = true
+	f.lastErr = err.Error()
+	f.switchedAt = time.Now()
+	f.mu.Unlock()
+	log.Printf("failover: primary unavailable at startup (%v); writes go to secondary", err)
+}
+
+// writer returns the database writes should go to.
+func (f *failover) writer() *sql.DB {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.onSecondary {
+		return f.secondary
+	}
+	return f.primary
+}
+
+// currentDB is a database.Querier that runs each statement on the
+// database its func returns at the time, such as failover.writer, so a
+// handler built once at startup follows a failover instead of staying
+// bound to the primary.
+type currentDB func() *sql.DB
+
+func (c currentDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return c().ExecContext(ctx, query, args...)
+}
+
+func (c currentDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return c().QueryContext(ctx, query, args...)
+}
+
+func (c currentDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return c().QueryRowContext(ctx, query, args...)
+}
+
+func (f *failover) ping(ctx context.Context, db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+	return db.PingContext(ctx)
+}
+
+// check pings the primary once and switches if the streak reaches the
+// threshold. Checks are serialized by checking; mu is only held between
+// pings, so writer never waits on the network.
+func (f *failover) check(ctx context.Context) {
+	f.checking.Lock()
+	defer f.checking.Unlock()
+
+	err := f.ping(ctx, f.primary)
+	f.mu.Lock()
+	onSecondary := f.onSecondary
+	f.lastErr = ""
+	if err != nil {
+		f.lastErr = err.Error()
+	}
+	if (err != nil) != onSecondary {
+		f.streak++
+	} else {
+		f.streak = 0
+	}
+	due := f.streak >= f.threshold
+	f.mu.Unlock()
+
+	if !due {
+		return
+	}
+	if !onSecondary {
+		if serr := f.ping(ctx, f.secondary); serr != nil {
+			log.Printf("failover: primary down (%v) but secondary unavailable: %v", err, serr)
+			return
+		}
+	}
+
+	f.mu.Lock()
+	f.onSecondary = !onSecondary
+	f.streak = 0
+	f.switchedAt = time.Now()
+	f.mu.Unlock()
+	if onSecondary {
+		log.Printf("failover: primary passed %d health checks; writes switched back to primary", f.threshold)
+	} else {
+		log.Printf("failover: primary failed %d health checks (%v); writes switched to secondary", f.threshold, err)
+	}
+}
+
+// monitor runs check on every tick until ctx is done.
+func (f *failover) monitor(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			f.check(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+type failoverStatus struct {
+	Active     string     `json:"active"`
+	Streak     int        `json:"streak"`
+	Threshold  int        `json:"threshold"`
+	LastError  string     `json:"last_error,omitempty"`
+	SwitchedAt *time.Time `json:"switched_at,omitempty"`
+}
+
+func (f *failover) status() failoverStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := failoverStatus{Active: "primary", Streak: f.streak, Threshold: f.threshold, LastError: f.lastErr}
+	if f.onSecondary {
+		s.Active = "secondary"
+	}
+	if !f.switchedAt.IsZero() {
+		at := f.switchedAt
+		s.SwitchedAt = &at
+	}
+	return s
+}
+
+// serveHTTP reports which database takes writes. POST runs a health check
+// first instead of waiting for the next tick; it switches under the same
+// rules as the monitor.
+func (f *failover) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		f.check(r.Context())
+	}
+	web.WriteJSON(w, http.StatusOK, f.status())
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+
+	database "golang/MySQL-Database"
+)
+
+func newPingMock(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+	return db, mock
+}
+
+// failoverUsers returns POST /users behind Backpressure reserving from
+// fo.writer, as main mounts it.
+func failoverUsers(db *sql.DB, fo *failover) http.Handler {
+	r := mux.NewRouter()
+	users := &userHandler{db: db, failover: fo, router: r, passwords: passwordPolicy{MinLength: 1}}
+	ur := r.PathPrefix("/users").Subrouter()
+	ur.Use(database.BackpressureFunc(fo.writer, time.Second, time.Second))
+	ur.HandleFunc("", users.create).Methods(http.MethodPost)
+	ur.HandleFunc("/{id:[0-9]+}", users.get).Methods(http.MethodGet).Name("user")
+	return r
+}
+
+func createUser(t *testing.T, h http.Handler, name string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"username":"`+name+`","password":"pw"}`))
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /users = %d %s, want 201", rec.Code, rec.Body)
+	}
+}
+
+func TestFailoverRoutesWritesToSecondaryAndBack(t *testing.T) {
+	primary, pm := newPingMock(t)
+	secondary, sm := newPingMock(t)
+	fo := newFailover(primary, secondary, 2, time.Second)
+	h := failoverUsers(primary, fo)
+	down := errors.New("connection refused")
+
+	pm.ExpectExec("INSERT INTO users").WillReturnResult(sqlmock.NewResult(1, 1))
+	createUser(t, h, "before")
+
+	// Two failed pings reach the threshold; the secondary is pinged
+	// before it is promoted.
+	pm.ExpectPing().WillReturnError(down)
+	fo.check(context.Background())
+	if fo.writer() != primary {
+		t.Fatal("switched after one failure, want threshold 2")
+	}
+	pm.ExpectPing().WillReturnError(down)
+	sm.ExpectPing()
+	fo.check(context.Background())
+	if fo.writer() != secondary {
+		t.Fatal("writes not switched to secondary after 2 failures")
+	}
+	if got := fo.status().Active; got != "secondary" {
+		t.Errorf("status active = %q, want secondary", got)
+	}
+
+	// With the primary down, Backpressure must reserve from the
+	// secondary rather than fail with 503.
+	sm.ExpectExec("INSERT INTO users").WillReturnResult(sqlmock.NewResult(2, 1))
+	createUser(t, h, "during")
+
+	pm.ExpectPing()
+	pm.ExpectPing()
+	fo.check(context.Background())
+	fo.check(context.Background())
+	if fo.writer() != primary {
+		t.Fatal("writes not switched back after primary recovered")
+	}
+	pm.ExpectExec("INSERT INTO users").WillReturnResult(sqlmock.NewResult(3, 1))
+	createUser(t, h, "after")
+}
+
+func TestFailoverStaysWhenSecondaryDown(t *testing.T) {
+	primary, pm := newPingMock(t)
+	secondary, sm := newPingMock(t)
+	fo := newFailover(primary, secondary, 1, time.Second)
+
+	pm.ExpectPing().WillReturnError(errors.New("down"))
+	sm.ExpectPing().WillReturnError(errors.New("down too"))
+	fo.check(context.Background())
+	if fo.writer() != primary {
+		t.Error("promoted a secondary that failed its ping")
+	}
+}
+
+func TestFailoverStartOnSecondary(t *testing.T) {
+	primary, _ := newPingMock(t)
+	secondary, _ := newPingMock(t)
+	fo := newFailover(primary, secondary, 3, time.Second)
+	fo.startOnSecondary(errors.New("down at startup"))
+	if fo.writer() != secondary {
+		t.Error("writer is not the secondary after startOnSecondary")
+	}
+}
+
+func TestPublicConfigOmitsUnsetSecondary(t *testing.T) {
+	if got := (config{}).public().SecondaryDSN; got != "" {
+		t.Errorf("public SecondaryDSN = %q for an unset DSN, want empty", got)
+	}
+	cfg := config{SecondaryDSN: "app:secret@tcp(db2:3306)/app"}
+	if got := cfg.public().SecondaryDSN; got == "" || strings.Contains(got, "secret") {
+		t.Errorf("public SecondaryDSN = %q, want it redacted", got)
+	}
+}
+
+func TestCurrentDBFollowsFailover(t *testing.T) {
+	primary, pm := newPingMock(t)
+	secondary, sm := newPingMock(t)
+	fo := newFailover(primary, secondary, 1, time.Second)
+	store := &database.MySQLSessionStore{DB: currentDB(fo.writer), TTL: time.Hour}
+
+	pm.ExpectExec("INSERT INTO sessions").WillReturnResult(sqlmock.NewResult(1, 1))
+	if _, _, err := store.Create(context.Background(), 7); err != nil {
+		t.Fatal(err)
+	}
+
+	fo.startOnSecondary(errors.New("down"))
+	sm.ExpectExec("INSERT INTO sessions").WillReturnResult(sqlmock.NewResult(2, 1))
+	if _, _, err := store.Create(context.Background(), 7); err != nil {
+		t.Fatalf("create on the secondary: %v", err)
+	}
+	sm.ExpectExec("DELETE FROM sessions WHERE expires_at").WillReturnResult(sqlmock.NewResult(0, 3))
+	if n, err := store.DeleteExpired(context.Background()); err != nil || n != 3 {
+		t.Errorf("prune on the secondary = %d, %v; want 3", n, err)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var gzipWriters = sync.Pool{
+	New: func() interface{} { return gzip.NewWriter(nil) },
+}
+
+// GzipMiddleware compresses responses for clients that accept gzip.
+// Responses that are already compressed (images, archives, ...) or carry
+// their own Content-Encoding are passed through, as are bodyless statuses.
+// The gzip stream is closed when the handler returns, however it returns.
+func GzipMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		varyOn(r, "Accept-Encoding")
+		if r.Method == http.MethodHead || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		gw := &gzipWriter{ResponseWriter: w}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// acceptsGzip reports whether an Accept-Encoding value allows gzip with a
+// non-zero q, by name or, when gzip isn't named, through *.
+func acceptsGzip(header string) bool {
+	gzipQ, starQ := -1.0, -1.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if k, v, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(k) == "q" {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				q = f
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "gzip":
+			gzipQ = q
+		case "*":
+			starQ = q
+		}
+	}
+	if gzipQ >= 0 {
+		return gzipQ > 0
+	}
+	return starQ > 0
+}
+
+// compressible reports whether a body of the given Content-Type is worth
+// compressing. Media and archive formats are compressed already.
+func compressible(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return true
+	}
+	switch {
+	case mt == "image/svg+xml":
+		return true
+	case strings.HasPrefix(mt, "image/"), strings.HasPrefix(mt, "video/"), strings.HasPrefix(mt, "audio/"):
+		return false
+	}
+	switch mt {
+	case "application/gzip", "application/x-gzip", "application/zip", "application/zstd",
+		"application/x-bzip2", "application/x-7z-compressed", "font/woff", "font/woff2":
+		return false
+	}
+	return true
+}
+
+// gzipWriter decides on the first WriteHeader, Write or Flush whether to
+// compress, once the handler's headers are known. Each of them can send the
+// headers, so Content-Encoding must be settled before any is forwarded.
+type gzipWriter struct {
+	http.ResponseWriter
+	gz      *gzip.Writer
+	decided bool
+}
+
+func (gw *gzipWriter) decide(status int, first []byte) {
+	// Informational responses go out ahead of the real one, which is
+	// still to be decided.
+	if gw.decided || status < 200 {
+		return
+	}
+	gw.decided = true
+
+	h := gw.Header()
+	if status == http.StatusNoContent || status == http.StatusNotModified ||
+		status == http.StatusPartialContent || h.Get("Content-Encoding") != "" {
+		return
+	}
+	ct := h.Get("Content-Type")
+	if ct == "" {
+		// net/http would sniff the gzip bytes, so sniff the plain ones
+		// here. Without them, as on an explicit WriteHeader, the type is
+		// unknown and the body is left alone.
+		if first == nil {
+			return
+		}
+		ct = http.DetectContentType(first)
+		h.Set("Content-Type", ct)
+	}
+	if !compressible(ct) {
+		return
+	}
+	h.Del("Content-Length")
+	h.Set("Content-Encoding", "gzip")
+	gw.gz = gzipWriters.Get().(*gzip.Writer)
+	gw.gz.Reset(gw.ResponseWriter)
+}
+
+func (gw *gzipWriter) WriteHeader(code int) {
+	gw.decide(code, nil)
+	gw.ResponseWriter.WriteHeader(code)
+}
+
+func (gw *gzipWriter) Write(b []byte) (int, error) {
+	if !gw.decided {
+		gw.decide(http.StatusOK, b)
+	}
+	if gw.gz == nil {
+		return gw.ResponseWriter.Write(b)
+	}
+	return gw.gz.Write(b)
+}
+
+func (gw *gzipWriter) Flush() {
+	gw.decide(http.StatusOK, nil)
+	if gw.gz != nil {
+		gw.gz.Flush()
+	}
+	if f, ok := gw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (gw *gzipWriter) close() {
+	if gw.gz == nil {
+		return
+	}
+	gw.gz.Close()
+	gw.gz.Reset(nil)
+	gzipWriters.Put(gw.gz)
+	gw.gz = nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// gzipGet fetches h through GzipMiddleware over a real connection, so
+// headers go out when the handler flushes, and returns the response with
+// its raw, still encoded, body.
+func gzipGet(t *testing.T, h http.HandlerFunc) (*http.Response, []byte) {
+	t.Helper()
+	srv := httptest.NewServer(GzipMiddleware(h))
+	t.Cleanup(srv.Close)
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, body
+}
+
+// plainBody undoes the Content-Encoding of resp, if any.
+func plainBody(t *testing.T, resp *http.Response, body []byte) string {
+	t.Helper()
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		return string(body)
+	}
+	zr, err := gzip.NewReader(strings.NewReader(string(body)))
+	if err != nil {
+		t.Fatalf("Content-Encoding is gzip but the body isn't: %v", err)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestGzipCompressesJSON(t *testing.T) {
+	payload := `[` + strings.Repeat(`{"username":"alice","email":"alice@example.com"},`, 200) + `{}]`
+	resp, body := gzipGet(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, payload)
+	})
+
+	if got := resp.Header.Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", got)
+	}
+	if len(body) >= len(payload) {
+		t.Errorf("compressed body is %d bytes, uncompressed %d", len(body), len(payload))
+	}
+	if got := plainBody(t, resp, body); got != payload {
+		t.Errorf("decompressed body differs from what the handler wrote")
+	}
+}
+
+func TestGzipSkipsCompressedTypes(t *testing.T) {
+	resp, body := gzipGet(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		io.WriteString(w, "not really a png")
+	})
+	if got := resp.Header.Get("Content-Encoding"); got != "" {
+		t.Errorf("Content-Encoding = %q, want none", got)
+	}
+	if string(body) != "not really a png" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestGzipFlushBeforeWrite(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+	}{
+		{"known type", "text/plain; charset=utf-8"},
+		{"unknown type", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := gzipGet(t, func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				w.(http.Flusher).Flush()
+				io.WriteString(w, "first ")
+				w.(http.Flusher).Flush()
+				io.WriteString(w, "second")
+			})
+			// Whichever way it was decided, the header must match the body.
+			if got := plainBody(t, resp, body); got != "first second" {
+				t.Errorf("body = %q, want %q (Content-Encoding %q)", got, "first second", resp.Header.Get("Content-Encoding"))
+			}
+		})
+	}
+}
+
+func TestGzipWriteHeaderThenWrite(t *testing.T) {
+	resp, body := gzipGet(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusCreated)
+		w.(http.Flusher).Flush()
+		io.WriteString(w, "created")
+	})
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("status = %d, want 201", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Content-Encoding"); got != "gzip" {
+		t.Errorf("Content-Encoding = %q, want gzip", got)
+	}
+	if got := plainBody(t, resp, body); got != "created" {
+		t.Errorf("body = %q, want created", got)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"golang/web"
+)
+
+// limitHeaderBytes answers requests whose request line and headers take
+// more than max bytes with a JSON 431. net/http has its own limit,
+// http.Server.MaxHeaderBytes, but it answers with a bare text 431 before
+// any handler runs. That limit is left at its 1 MiB default and max is
+// kept well below it, so this check is the one clients normally see.
+func limitHeaderBytes(max int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if headerBytes(r) > max {
+				w.Header().Set("Connection", "close")
+				web.WriteJSON(w, http.StatusRequestHeaderFieldsTooLarge, map[string]interface{}{
+					"error":     fmt.Sprintf("request headers exceed %d bytes", max),
+					"max_bytes": max,
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// headerBytes is the size of r's request line and headers as sent, give or
+// take whitespace. net/http moves Host out of r.Header, so it is counted
+// separately.
+func headerBytes(r *http.Request) int {
+	n := len(r.Method) + 1 + len(r.RequestURI) + 1 + len(r.Proto) + 2
+	n += len("Host: ") + len(r.Host) + 2
+	for k, vs := range r.Header {
+		for _, v := range vs {
+			n += len(k) + 2 + len(v) + 2
+		}
+	}
+	return n
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLimitHeaderBytes(t *testing.T) {
+	const limit = 1024
+	srv := httptest.NewServer(limitHeaderBytes(limit)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})))
+	defer srv.Close()
+
+	get := func(cookie string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/books", nil)
+		req.Header.Set("Cookie", cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := get("session=" + strings.Repeat("a", 2*limit))
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestHeaderFieldsTooLarge {
+		t.Fatalf("oversized headers = %d, want 431", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want the JSON body rather than net/http's text", ct)
+	}
+	var body struct {
+		Error    string `json:"error"`
+		MaxBytes int    `json:"max_bytes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.MaxBytes != limit || !strings.Contains(body.Error, "1024 bytes") {
+		t.Errorf("body = %+v, %v; want the limit explained", body, err)
+	}
+
+	small := get("session=abc")
+	small.Body.Close()
+	if small.StatusCode != http.StatusOK {
+		t.Errorf("small headers = %d, want 200", small.StatusCode)
+	}
+}
+
+func TestHeaderBytesCountsHost(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "example.com"
+	short := headerBytes(req)
+	req.Host = strings.Repeat("h", 500)
+	if long := headerBytes(req); long-short != 500-len("example.com") {
+		t.Errorf("headerBytes grew by %d for a longer Host, want %d", long-short, 500-len("example.com"))
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	database "golang/MySQL-Database"
+	"golang/web"
+)
+
+type healthCheckKey struct{}
+
+// healthChecks recognises load balancer and orchestrator probes by path or
+// User-Agent substring. The request loggers skip matched requests unless
+// log is set, so probes hitting every few seconds don't bury real traffic.
+type healthChecks struct {
+	paths  map[string]bool
+	agents []string
+	log    bool
+}
+
+func newHealthChecks(paths, agents []string, log bool) *healthChecks {
+	hc := &healthChecks{paths: make(map[string]bool, len(paths)), agents: agents, log: log}
+	for _, p := range paths {
+		hc.paths[p] = true
+	}
+	return hc
+}
+
+func (hc *healthChecks) match(r *http.Request) bool {
+	if hc.paths[r.URL.Path] {
+		return true
+	}
+	ua := r.UserAgent()
+	for _, agent := range hc.agents {
+		if strings.Contains(ua, agent) {
+			return true
+		}
+	}
+	return false
+}
+
+// middleware marks probe requests so loggers further down can skip them.
+func (hc *healthChecks) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !hc.log && hc.match(r) {
+			r = r.WithContext(context.WithValue(r.Context(), healthCheckKey{}, true))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// quietRequest reports whether r was marked as a probe that should not be
+// logged.
+func quietRequest(r *http.Request) bool {
+	quiet, _ := r.Context().Value(healthCheckKey{}).(bool)
+	return quiet
+}
+
+// healthz answers 200 {"status":"ok"} while db answers a ping within
+// timeout and 503 {"status":"unavailable"} otherwise.
+func healthz(db database.Pinger, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		if err := db.PingContext(ctx); err != nil {
+			log.Printf("healthz: ping database: %v", err)
+			web.WriteJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "unavailable"})
+			return
+		}
+		web.WriteJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	}
+}
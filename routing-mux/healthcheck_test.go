@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthChecksAreNotLogged(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	tests := []struct {
+		name      string
+		path, ua  string
+		logChecks bool
+		logged    bool
+	}{
+		{"probe path", "/healthz", "curl/8.0", false, false},
+		{"probe agent", "/", "kube-probe/1.29", false, false},
+		{"normal request", "/books/dune", "curl/8.0", false, true},
+		{"probes logged when asked", "/healthz", "kube-probe/1.29", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLog(t)
+			hc := newHealthChecks([]string{"/healthz"}, []string{"kube-probe"}, tt.logChecks)
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("User-Agent", tt.ua)
+
+			hc.middleware(LoggingMiddleware(ok)).ServeHTTP(httptest.NewRecorder(), req)
+
+			if logged := strings.Contains(logs.String(), tt.path); logged != tt.logged {
+				t.Errorf("logged = %v, want %v: %q", logged, tt.logged, logs)
+			}
+		})
+	}
+}
+
+func TestHealthzPingFails(t *testing.T) {
+	captureLog(t)
+	db, mock := newPingMock(t)
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+
+	rec := httptest.NewRecorder()
+	healthz(db, time.Second)(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), `"status":"unavailable"`) {
+		t.Fatalf("got %d %s, want 503 unavailable", rec.Code, rec.Body)
+	}
+}
+
+func TestHealthzPingSucceeds(t *testing.T) {
+	db, mock := newPingMock(t)
+	mock.ExpectPing()
+
+	rec := httptest.NewRecorder()
+	healthz(db, time.Second)(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"status":"ok"`) {
+		t.Fatalf("got %d %s, want 200 ok", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang/web"
+)
+
+// finishedJobTTL is how long a finished job, and its output, can still be
+// fetched.
+const finishedJobTTL = time.Hour
+
+const (
+	jobRunning   = "running"
+	jobDone      = "done"
+	jobFailed    = "failed"
+	jobCancelled = "cancelled"
+)
+
+var (
+	errJobNotFound = errors.New("job not found")
+	errJobFinished = errors.New("job already finished")
+)
+
+// job is a long-running task, such as an export, executing in the
+// background under its own cancellable context.
+type job struct {
+	ID string
+	// output is the file the job writes its result to, or "" if it has
+	// none. It is only read once the job is done.
+	output string
+	// done is closed once the job's function has returned, which for a
+	// cancelled job can be a little after its state changed.
+	done chan struct{}
+
+	mu       sync.Mutex
+	state    string
+	err      error
+	cancel   context.CancelFunc
+	finished time.Time
+}
+
+type jobView struct {
+	ID    string `json:"id"`
+	State string `json:"state"`
+	Error string `json:"error,omitempty"`
+	// Result is where a done job's output can be fetched.
+	Result string `json:"result,omitempty"`
+}
+
+func (j *job) view() jobView {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	v := jobView{ID: j.ID, State: j.state}
+	if j.err != nil {
+		v.Error = j.err.Error()
+	}
+	if j.state == jobDone && j.output != "" {
+		v.Result = "/jobs/" + j.ID + "/result"
+	}
+	return v
+}
+
+// finish records the outcome of fn unless the job was cancelled first.
+func (j *job) finish(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.finished = time.Now()
+	if j.state != jobRunning {
+		return
+	}
+	if err != nil {
+		j.state, j.err = jobFailed, err
+	} else {
+		j.state = jobDone
+	}
+}
+
+// jobStore tracks background jobs so clients can poll or cancel them.
+// Finished jobs are kept for keep, then dropped with their output.
+type jobStore struct {
+	keep time.Duration
+
+	mu     sync.Mutex
+	jobs   map[string]*job
+	lastID int
+}
+
+func newJobStore(keep time.Duration) *jobStore {
+	return &jobStore{keep: keep, jobs: make(map[string]*job)}
+}
+
+// start runs fn in a new goroutine. fn must return promptly once its
+// context is cancelled. output names the file fn writes its result to,
+// if any; fn should only create it once the result is complete, since
+// resultHandler serves whatever is there once fn has returned nil.
+func (s *jobStore) start(output string, fn func(ctx context.Context) error) *job {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s.mu.Lock()
+	s.lastID++
+	j := &job{ID: strconv.Itoa(s.lastID), output: output, done: make(chan struct{}), state: jobRunning, cancel: cancel}
+	s.jobs[j.ID] = j
+	s.mu.Unlock()
+
+	go func() {
+		defer close(j.done)
+		defer cancel()
+		j.finish(fn(ctx))
+	}()
+	return j
+}
+
+// sweep drops the jobs that finished more than keep before now and
+// removes their output.
+func (s *jobStore) sweep(now time.Time) {
+	s.mu.Lock()
+	var expired []*job
+	for id, j := range s.jobs {
+		j.mu.Lock()
+		if !j.finished.IsZero() && now.Sub(j.finished) > s.keep {
+			expired = append(expired, j)
+			delete(s.jobs, id)
+		}
+		j.mu.Unlock()
+	}
+	s.mu.Unlock()
+
+	for _, j := range expired {
+		if j.output == "" {
+			continue
+		}
+		if err := os.Remove(j.output); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("job %s: remove output: %v", j.ID, err)
+		}
+	}
+}
+
+// sweepEvery runs sweep on every tick until ctx is done.
+func (s *jobStore) sweepEvery(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case now := <-t.C:
+			s.sweep(now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *jobStore) get(id string) (*job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	return j, ok
+}
+
+// cancelJob stops a running job and marks it cancelled.
+func (s *jobStore) cancelJob(id string) (*job, error) {
+	j, ok := s.get(id)
+	if !ok {
+		return nil, errJobNotFound
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.state != jobRunning {
+		return j, errJobFinished
+	}
+	j.state = jobCancelled
+	j.cancel()
+	return j, nil
+}
+
+func (s *jobStore) getHandler(w http.ResponseWriter, r *http.Request) {
+	j, ok := s.get(pathVar(r, "id"))
+	if !ok {
+		web.WriteError(w, http.StatusNotFound, errJobNotFound.Error())
+		return
+	}
+	web.WriteJSON(w, http.StatusOK, j.view())
+}
+
+func (s *jobStore) cancelHandler(w http.ResponseWriter, r *http.Request) {
+	j, err := s.cancelJob(pathVar(r, "id"))
+	switch {
+	case errors.Is(err, errJobNotFound):
+		web.WriteError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, errJobFinished):
+		web.WriteError(w, http.StatusConflict, err.Error())
+	default:
+		web.WriteJSON(w, http.StatusOK, j.view())
+	}
+}
+
+// resultHandler serves the output of a done job. Jobs still running, or
+// that failed or were cancelled, answer 409.
+func (s *jobStore) resultHandler(w http.ResponseWriter, r *http.Request) {
+	j, ok := s.get(pathVar(r, "id"))
+	if !ok {
+		web.WriteError(w, http.StatusNotFound, errJobNotFound.Error())
+		return
+	}
+	j.mu.Lock()
+	state := j.state
+	j.mu.Unlock()
+	switch {
+	case state != jobDone:
+		web.WriteError(w, http.StatusConflict, "job is "+state)
+		return
+	case j.output == "":
+		web.WriteError(w, http.StatusNotFound, "job has no result")
+		return
+	}
+
+	f, err := os.Open(j.output)
+	if err != nil {
+		log.Printf("request_id=%s job %s: open output: %v", RequestIDFromContext(r.Context()), j.ID, err)
+		web.WriteError(w, http.StatusInternalServerError, "could not read job result")
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		web.WriteError(w, http.StatusInternalServerError, "could not read job result")
+		return
+	}
+	name := filepath.Base(j.output)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
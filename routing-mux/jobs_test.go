@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// waitDone waits for the function of job id to return.
+func waitDone(t *testing.T, s *jobStore, id string) {
+	t.Helper()
+	j, ok := s.get(id)
+	if !ok {
+		t.Fatalf("job %s not found", id)
+	}
+	select {
+	case <-j.done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("job %s did not stop", id)
+	}
+}
+
+func TestCancelRunningExport(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// The query takes a minute unless its context is cancelled, so the
+	// job is still running when DELETE arrives.
+	mock.ExpectQuery(`FROM users u LEFT JOIN comments c`).
+		WillDelayFor(time.Minute).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	dir := t.TempDir()
+	jobs := newJobStore(time.Hour)
+	h := jobRouter(&exportHandler{db: db, jobs: jobs, dir: dir})
+	id := startExport(t, h).ID
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/jobs/"+id, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("DELETE running job = %d %s, want 200", rec.Code, rec.Body)
+	}
+	waitDone(t, jobs, id)
+
+	if got := waitJob(t, h, id).State; got != jobCancelled {
+		t.Errorf("state after cancel = %q, want %q", got, jobCancelled)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("export dir holds %d files after cancel, want none", len(entries))
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/"+id+"/result", nil))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("result of cancelled job = %d, want 409", rec.Code)
+	}
+}
+
+func TestCancelFinishedJob(t *testing.T) {
+	jobs := newJobStore(time.Hour)
+	h := jobRouter(&exportHandler{jobs: jobs})
+	j := jobs.start("", func(ctx context.Context) error { return nil })
+	waitDone(t, jobs, j.ID)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/jobs/"+j.ID, nil))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("DELETE finished job = %d, want 409", rec.Code)
+	}
+	if got := j.view().State; got != jobDone {
+		t.Errorf("state = %q, want it left %q", got, jobDone)
+	}
+}
+
+func TestCancelUnknownJob(t *testing.T) {
+	h := jobRouter(&exportHandler{jobs: newJobStore(time.Hour)})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/jobs/42", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("DELETE unknown job = %d, want 404", rec.Code)
+	}
+}
+
+func TestFailedJobReportsError(t *testing.T) {
+	jobs := newJobStore(time.Hour)
+	j := jobs.start("", func(ctx context.Context) error { return errors.New("disk full") })
+	waitDone(t, jobs, j.ID)
+	if v := j.view(); v.State != jobFailed || v.Error != "disk full" {
+		t.Errorf("view = %+v, want failed with the error", v)
+	}
+}
+
+func TestJobSweepRemovesOutput(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "result.json")
+	jobs := newJobStore(time.Hour)
+	j := jobs.start(out, func(ctx context.Context) error { return os.WriteFile(out, []byte("[]\n"), 0o600) })
+	waitDone(t, jobs, j.ID)
+
+	jobs.sweep(time.Now())
+	if _, ok := jobs.get(j.ID); !ok {
+		t.Fatal("job swept before keep elapsed")
+	}
+	jobs.sweep(time.Now().Add(2 * time.Hour))
+	if _, ok := jobs.get(j.ID); ok {
+		t.Error("job still listed after keep elapsed")
+	}
+	if _, err := os.Stat(out); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("output still present after sweep: %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"golang/web"
+)
+
+// latencyBounds are the upper bounds of the histogram buckets: 60 buckets
+// growing by 25% each, from 500µs to roughly 80s. Estimates are accurate to
+// within one bucket width without keeping individual samples.
+var latencyBounds = func() []time.Duration {
+	bounds := make([]time.Duration, 60)
+	b := float64(500 * time.Microsecond)
+	for i := range bounds {
+		bounds[i] = time.Duration(b)
+		b *= 1.25
+	}
+	return bounds
+}()
+
+// latencyHistogram counts observations per bucket; the final slot holds
+// everything above the last bound.
+type latencyHistogram struct {
+	counts [61]uint64
+	total  uint64
+}
+
+func (h *latencyHistogram) observe(d time.Duration) {
+	i := sort.Search(len(latencyBounds), func(i int) bool { return d <= latencyBounds[i] })
+	h.counts[i]++
+	h.total++
+}
+
+// quantile estimates the q-th quantile by interpolating linearly inside the
+// bucket holding it.
+func (h *latencyHistogram) quantile(q float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	rank := q * float64(h.total)
+	var seen float64
+	for i, c := range h.counts {
+		if c == 0 {
+			continue
+		}
+		if seen+float64(c) >= rank {
+			if i == len(latencyBounds) {
+				return latencyBounds[i-1]
+			}
+			var lower time.Duration
+			if i > 0 {
+				lower = latencyBounds[i-1]
+			}
+			frac := (rank - seen) / float64(c)
+			return lower + time.Duration(frac*float64(latencyBounds[i]-lower))
+		}
+		seen += float64(c)
+	}
+	return latencyBounds[len(latencyBounds)-1]
+}
+
+// latencyStats keeps one histogram per route.
+type latencyStats struct {
+	mu     sync.Mutex
+	routes map[string]*latencyHistogram
+}
+
+func newLatencyStats() *latencyStats {
+	return &latencyStats{routes: make(map[string]*latencyHistogram)}
+}
+
+func (s *latencyStats) observe(route string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, ok := s.routes[route]
+	if !ok {
+		h = new(latencyHistogram)
+		s.routes[route] = h
+	}
+	h.observe(d)
+}
+
+func (s *latencyStats) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		s.observe(routeName(r), time.Since(start))
+	})
+}
+
+type latencySummary struct {
+	Count uint64  `json:"count"`
+	P50   float64 `json:"p50_ms"`
+	P90   float64 `json:"p90_ms"`
+	P99   float64 `json:"p99_ms"`
+}
+
+// serveHTTP reports the estimated percentiles in milliseconds per route.
+func (s *latencyStats) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+
+	s.mu.Lock()
+	out := make(map[string]latencySummary, len(s.routes))
+	for route, h := range s.routes {
+		out[route] = latencySummary{
+			Count: h.total,
+			P50:   ms(h.quantile(0.50)),
+			P90:   ms(h.quantile(0.90)),
+			P99:   ms(h.quantile(0.99)),
+		}
+	}
+	s.mu.Unlock()
+
+	web.WriteJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLatencyPercentiles(t *testing.T) {
+	s := newLatencyStats()
+	// One request each at 1ms, 2ms, ... 100ms.
+	for i := 1; i <= 100; i++ {
+		s.observe("/books/{title}", time.Duration(i)*time.Millisecond)
+	}
+
+	rec := httptest.NewRecorder()
+	s.serveHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/latency", nil))
+	var got map[string]latencySummary
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	sum := got["/books/{title}"]
+	if sum.Count != 100 {
+		t.Errorf("count = %d, want 100", sum.Count)
+	}
+	// Buckets grow by 25%, so that is as close as an estimate gets.
+	for _, c := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"p50", sum.P50, 50},
+		{"p90", sum.P90, 90},
+		{"p99", sum.P99, 99},
+	} {
+		if math.Abs(c.got-c.want) > 0.25*c.want {
+			t.Errorf("%s = %.2fms, want %vms ± 25%%", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestLatencyQuantileEmptyAndOverflow(t *testing.T) {
+	var h latencyHistogram
+	if got := h.quantile(0.5); got != 0 {
+		t.Errorf("quantile of no observations = %v, want 0", got)
+	}
+	h.observe(10 * time.Minute)
+	if got, max := h.quantile(0.99), latencyBounds[len(latencyBounds)-1]; got != max {
+		t.Errorf("quantile above the last bucket = %v, want the last bound %v", got, max)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// LoggingMiddleware writes one access-log line per request with its
+// request and correlation ids, method, path, status and duration.
+// Health-check probes are left out. When the request context carries an
+// OpenTelemetry span, as it does behind an otelhttp handler, the line ends
+// with its trace_id and span_id so it can be found from the trace and the
+// other way round. A body snapshot taken by logBodies is appended as body.
+func LoggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := newStatusRecorder(w)
+		r, body := withBodySnapshot(r)
+		next.ServeHTTP(rec, r)
+		if quietRequest(r) {
+			return
+		}
+		var extra string
+		if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+			extra = " trace_id=" + sc.TraceID().String() + " span_id=" + sc.SpanID().String()
+		}
+		if *body != "" {
+			extra += fmt.Sprintf(" body=%q", *body)
+		}
+		log.Printf("request_id=%s correlation_id=%s %s %s %d %s%s",
+			RequestIDFromContext(r.Context()), CorrelationIDFromContext(r.Context()),
+			r.Method, r.URL.Path, rec.status, time.Since(start).Round(time.Microsecond), extra)
+	})
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestStatusRecorderRecords404(t *testing.T) {
+	w := httptest.NewRecorder()
+	rec := newStatusRecorder(w)
+	http.NotFound(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	if rec.status != http.StatusNotFound || w.Code != http.StatusNotFound {
+		t.Errorf("recorded %d, wrote %d; want 404 for both", rec.status, w.Code)
+	}
+}
+
+func TestStatusRecorderDefaultsTo200(t *testing.T) {
+	rec := newStatusRecorder(httptest.NewRecorder())
+	rec.Write([]byte("ok"))
+	rec.WriteHeader(http.StatusTeapot)
+	if rec.status != http.StatusOK {
+		t.Errorf("status = %d, want 200 from the implicit header", rec.status)
+	}
+}
+
+func TestLoggingMiddlewareLogsStatus(t *testing.T) {
+	logs := captureLog(t)
+	LoggingMiddleware(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	if line := logs.String(); !strings.Contains(line, " GET /missing 404 ") {
+		t.Errorf("log line = %q, want method, path and 404", line)
+	}
+}
+
+// memTracer stands in for otelhttp: it starts a span for every request,
+// puts it in the request context and remembers it.
+type memTracer struct {
+	spans []trace.SpanContext
+}
+
+func (mt *memTracer) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var cfg trace.SpanContextConfig
+		rand.Read(cfg.TraceID[:])
+		rand.Read(cfg.SpanID[:])
+		cfg.TraceFlags = trace.FlagsSampled
+		sc := trace.NewSpanContext(cfg)
+		mt.spans = append(mt.spans, sc)
+		next.ServeHTTP(w, r.WithContext(trace.ContextWithSpanContext(r.Context(), sc)))
+	})
+}
+
+func TestLoggingMiddlewareLogsTraceIDs(t *testing.T) {
+	logs := captureLog(t)
+	tracer := &memTracer{}
+	tracer.middleware(LoggingMiddleware(http.NotFoundHandler())).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/books/dune", nil))
+
+	if len(tracer.spans) != 1 {
+		t.Fatalf("tracer started %d spans, want 1", len(tracer.spans))
+	}
+	sc := tracer.spans[0]
+	want := " trace_id=" + sc.TraceID().String() + " span_id=" + sc.SpanID().String()
+	if line := logs.String(); !strings.Contains(line, want) {
+		t.Errorf("log line = %q, want %q", line, want)
+	}
+}
+
+func TestLoggingMiddlewareWithoutSpan(t *testing.T) {
+	logs := captureLog(t)
+	LoggingMiddleware(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/books/dune", nil))
+
+	if line := logs.String(); strings.Contains(line, "trace_id") {
+		t.Errorf("log line = %q, want no trace fields without a span", line)
+	}
+}
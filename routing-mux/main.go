@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	database "golang/MySQL-Database"
+	"golang/web"
+)
+
+func main() {
+	checkOnly := flag.Bool("check-db", false, "check the MySQL connection, report the server version and exit")
+	flag.Parse()
+	cfg := loadConfig()
+
+	if cfg.MySQLCACert != "" {
+		if err := database.RegisterTLS(cfg.MySQLCACert); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *checkOnly {
+		os.Exit(runCheckDB(cfg))
+	}
+	db := openDB(cfg, cfg.DSN)
+	defer db.Close()
+	primaryErr := prepareDB(cfg, db, cfg.DSN)
+	if primaryErr != nil && cfg.SecondaryDSN == "" {
+		log.Fatal(primaryErr)
+	}
+
+	stats := newStatusStats()
+	latency := newLatencyStats()
+	metrics := newMetrics()
+	jobs := newJobStore(finishedJobTTL)
+	go jobs.sweepEvery(context.Background(), time.Minute)
+	books := newBookStore()
+	passwords := cfg.passwordPolicy()
+	users := &userHandler{db: db, passwords: passwords, avatarDefault: "identicon", avatarSize: 80, metadataKeys: cfg.UserMetadataKeys}
+	var fo *failover
+	pool := func() *sql.DB { return db }
+	if cfg.SecondaryDSN != "" {
+		secondary := openDB(cfg, cfg.SecondaryDSN)
+		defer secondary.Close()
+		secondaryErr := prepareDB(cfg, secondary, cfg.SecondaryDSN)
+		switch {
+		case primaryErr != nil && secondaryErr != nil:
+			log.Fatalf("%v; secondary: %v", primaryErr, secondaryErr)
+		case secondaryErr != nil:
+			log.Printf("failover: secondary unavailable at startup, failover will wait until it answers: %v", secondaryErr)
+		}
+		fo = newFailover(db, secondary, cfg.FailoverThreshold, time.Second)
+		if primaryErr != nil {
+			fo.startOnSecondary(primaryErr)
+		}
+		go fo.monitor(context.Background(), cfg.FailoverEvery)
+		users.failover = fo
+		pool = fo.writer
+	}
+	admin := BasicAuthMiddleware(cfg.AdminUsers)
+	probes := newHealthChecks(cfg.HealthCheckPaths, cfg.HealthCheckAgents, cfg.LogHealthChecks)
+	uploads := &uploadHandler{
+		dir:           cfg.UploadDir,
+		allowedTypes:  cfg.UploadAllowedTypes,
+		maxParts:      cfg.UploadMaxParts,
+		maxBytes:      cfg.UploadMaxBytes,
+		progressEvery: cfg.UploadProgressEvery,
+		minRate:       cfg.UploadMinBytesPerSec,
+		slowFor:       cfg.UploadSlowFor,
+	}
+	if err := os.MkdirAll(cfg.UploadDir, 0o755); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.MkdirAll(cfg.ExportDir, 0o700); err != nil {
+		log.Fatal(err)
+	}
+	// Exports, logins and sessions follow the failover like /users does.
+	writer := currentDB(pool)
+	exports := &exportHandler{db: writer, jobs: jobs, dir: cfg.ExportDir}
+	cache := newResponseCache(cfg.CacheTTL, cfg.CacheMaxEntries)
+	go cache.sweepEvery(context.Background(), time.Minute)
+	sessions, err := newSessionStore(cfg, writer)
+	if err != nil {
+		log.Fatal(err)
+	}
+	login, err := newLoginHandler(writer, sessions)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	r := mux.NewRouter()
+	users.router = r
+	r.Use(probes.middleware)
+	r.Use(RequestIDMiddleware)
+	r.Use(LoggingMiddleware)
+	r.Use(RecoverMiddleware)
+	if cfg.RateLimitPerSec > 0 {
+		limiter := newRateLimiter(float64(cfg.RateLimitPerSec), cfg.RateLimitBurst, cfg.TrustForwardedFor)
+		go limiter.sweepEvery(context.Background(), time.Minute)
+		r.Use(limiter.middleware)
+	}
+	if cfg.MaxConcurrentPerIP > 0 {
+		r.Use(newConcurrencyLimiter(cfg.MaxConcurrentPerIP, cfg.TrustForwardedFor).middleware)
+	}
+	if cfg.ShedP99Threshold > 0 {
+		r.Use(newLoadShedder(cfg.ShedP99Threshold, cfg.ShedWindow).middleware)
+	}
+	r.Use(checkContentLength)
+	r.Use(logBodies(cfg.LogBodyBytes))
+	r.Use(stats.middleware)
+	r.Use(latency.middleware)
+	r.Use(metrics.middleware)
+	r.Use(dedupeCookies(cfg.MaxCookies))
+	r.Use(vary)
+	r.Use(GzipMiddleware)
+	r.Use(transformResponses(maxTransformBody, prettyJSON))
+
+	checkUTF8 := utf8Body(cfg.SanitizeUTF8)
+
+	RegisterRoutes(r, books.routes(cache.middleware, checkUTF8))
+	RegisterRoutes(r, []Route{
+		{Method: "GET", Path: "/jobs/{id}", Handler: http.HandlerFunc(jobs.getHandler), Middlewares: middlewares(admin)},
+		{Method: "DELETE", Path: "/jobs/{id}", Handler: http.HandlerFunc(jobs.cancelHandler), Middlewares: middlewares(admin)},
+		{Method: "GET", Path: "/jobs/{id}/result", Handler: http.HandlerFunc(jobs.resultHandler), Middlewares: middlewares(admin)},
+		{Method: "GET", Path: "/healthz", Handler: http.HandlerFunc(healthz(db, time.Second))},
+		{Method: "GET", Path: "/openapi.json", Handler: http.HandlerFunc(serveOpenAPI)},
+		{Method: "GET", Path: "/metrics", Handler: http.HandlerFunc(metrics.serveHTTP)},
+		{Method: "POST", Path: "/upload", Handler: uploads},
+		{Method: "POST", Path: "/login", Handler: http.HandlerFunc(login.login)},
+		{Method: "POST", Path: "/logout", Handler: http.HandlerFunc(login.logout)},
+		{Method: "POST", Path: "/auth/password-strength", Handler: http.HandlerFunc(passwords.strengthHandler)},
+		{Method: "GET", Path: "/debug/config", Handler: debugConfig(cfg), Middlewares: middlewares(admin)},
+		{Method: "GET", Path: "/debug/headers", Handler: http.HandlerFunc(debugHeaders(cfg.Features["debug-headers"]))},
+		{Method: "GET", Path: "/export/full", Handler: http.HandlerFunc(exports.stream), Middlewares: middlewares(admin)},
+		{Method: "POST", Path: "/export/jobs", Handler: http.HandlerFunc(exports.create), Middlewares: middlewares(admin)},
+	})
+
+	// Router middleware only runs for matched routes, so the fallbacks get
+	// their request ID and access log line here.
+	fallback := NewChain(RequestIDMiddleware, LoggingMiddleware, metrics.middleware)
+	r.NotFoundHandler = fallback.Then(http.HandlerFunc(notFound))
+	r.MethodNotAllowedHandler = fallback.Then(methodNotAllowed(r))
+
+	ar := r.PathPrefix("/admin").Subrouter()
+	ar.Use(admin)
+	adminRoutes := []Route{
+		{Method: "GET", Path: "/status-stats", Handler: http.HandlerFunc(stats.serveHTTP)},
+		{Method: "GET", Path: "/latency", Handler: http.HandlerFunc(latency.serveHTTP)},
+	}
+	if fo != nil {
+		adminRoutes = append(adminRoutes,
+			Route{Method: "GET", Path: "/failover", Handler: http.HandlerFunc(fo.serveHTTP)},
+			Route{Method: "POST", Path: "/failover", Handler: http.HandlerFunc(fo.serveHTTP)},
+		)
+	}
+	RegisterRoutes(ar, adminRoutes)
+
+	ur := r.PathPrefix("/users").Subrouter()
+	ur.Use(database.BackpressureFunc(pool, cfg.PoolWait, time.Second))
+	RegisterRoutes(ur, []Route{
+		{Method: "GET", Path: "", Handler: http.HandlerFunc(users.list)},
+		{Method: "POST", Path: "", Handler: http.HandlerFunc(users.create), Middlewares: middlewares(requireBody, checkUTF8)},
+		{Method: "GET", Path: "/availability", Handler: http.HandlerFunc(users.availability)},
+		{Method: "GET", Path: "/top-commenters", Handler: http.HandlerFunc(users.topCommenters)},
+		{Method: "GET", Path: "/me", Handler: http.HandlerFunc(users.me), Middlewares: middlewares(RequireSession(sessions))},
+		{Method: "GET", Path: "/{id:[0-9]+}", Handler: http.HandlerFunc(users.get), Name: "user"},
+		{Method: "PATCH", Path: "/{id:[0-9]+}", Handler: http.HandlerFunc(users.patch), Middlewares: middlewares(requireBody, checkUTF8)},
+		{Method: "GET", Path: "/{id:[0-9]+}/avatar", Handler: http.HandlerFunc(users.avatar)},
+	})
+
+	handler := NewChain(
+		CORSMiddleware(cfg.CORSOrigins, cfg.CORSMethods, cfg.CORSHeaders),
+		canonicalPaths(cfg.RedirectNonCanonicalWrites),
+		limitHeaderBytes(cfg.MaxHeaderBytes),
+		limitPathSegments(cfg.MaxPathSegments),
+		strictSlash(r),
+	).Then(r)
+	srv := web.NewServer(cfg.Addr, handler, cfg.Timeouts)
+	cache.warm(srv.Handler, cfg.CacheWarmPaths)
+
+	log.Fatal(web.ListenAndServe(srv))
+}
+
+// openDB connects to dsn, waiting for the server as configured, and
+// migrates the schema. It exits on failure.
+func openDB(cfg config, dsn string) *sql.DB {
+	db, err := cfg.open(dsn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	database.ApplyPool(db, cfg.Pool)
+	return db
+}
+
+// prepareDB waits for db to answer and brings its schema up to date. A
+// database that is down at startup is only migrated on the next start.
+func prepareDB(cfg config, db *sql.DB, dsn string) error {
+	if err := database.PingWithRetry(context.Background(), db, cfg.ConnectAttempts, cfg.ConnectBackoff); err != nil {
+		return fmt.Errorf("ping MySQL at %s: %w", database.RedactDSN(dsn), err)
+	}
+	if err := database.Migrate(db); err != nil {
+		return fmt.Errorf("MySQL at %s: %w", database.RedactDSN(dsn), err)
+	}
+	return nil
+}
+
+// runCheckDB is main for -check-db. It returns the exit status: 0 if the
+// configured database passes checkDB, 1 otherwise.
+func runCheckDB(cfg config) int {
+	db, err := cfg.open(cfg.DSN)
+	if err == nil {
+		defer db.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		err = checkDB(ctx, db, os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "check-db: MySQL at %s: %v\n", database.RedactDSN(cfg.DSN), err)
+		return 1
+	}
+	return 0
+}
+
+// newSessionStore returns the store cfg.SessionStore names. The MySQL
+// store gets a goroutine that prunes expired sessions hourly.
+func newSessionStore(cfg config, db database.Querier) (SessionStore, error) {
+	switch cfg.SessionStore {
+	case "cookie":
+		return newCookieSessions(cfg.SessionKey, cfg.SessionTTL)
+	case "mysql":
+		store := &database.MySQLSessionStore{DB: db, TTL: cfg.SessionTTL}
+		go func() {
+			for range time.Tick(time.Hour) {
+				if _, err := store.DeleteExpired(context.Background()); err != nil {
+					log.Printf("prune sessions: %v", err)
+				}
+			}
+		}()
+		return store, nil
+	}
+	return nil, fmt.Errorf("SESSION_STORE: unknown store %q, want cookie or mysql", cfg.SessionStore)
+}
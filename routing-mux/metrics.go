@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// metricBuckets are the upper bounds, in seconds, of the request duration
+// histogram; they are Prometheus's default buckets.
+var metricBuckets = [...]float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metrics counts requests, responses by status class and request
+// durations, and serves them in the Prometheus text format at /metrics.
+// It is formatted by hand to keep the client library out.
+type metrics struct {
+	mu       sync.Mutex
+	requests uint64
+	classes  [len(statusClasses)]uint64
+	buckets  [len(metricBuckets)]uint64
+	sum      float64
+}
+
+func newMetrics() *metrics {
+	return &metrics{}
+}
+
+func (m *metrics) observe(status int, d time.Duration) {
+	secs := d.Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests++
+	if class := status/100 - 2; class >= 0 && class < len(statusClasses) {
+		m.classes[class]++
+	}
+	for i, le := range metricBuckets {
+		if secs <= le {
+			m.buckets[i]++
+		}
+	}
+	m.sum += secs
+}
+
+func (m *metrics) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := newStatusRecorder(w)
+		next.ServeHTTP(rec, r)
+		m.observe(rec.status, time.Since(start))
+	})
+}
+
+// serveHTTP writes the metrics in the Prometheus text exposition format.
+func (m *metrics) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	requests, classes, buckets, sum := m.requests, m.classes, m.buckets, m.sum
+	m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprintln(w, "# HELP http_requests_total Requests served.")
+	fmt.Fprintln(w, "# TYPE http_requests_total counter")
+	fmt.Fprintf(w, "http_requests_total %d\n", requests)
+
+	fmt.Fprintln(w, "# HELP http_responses_total Responses by status class.")
+	fmt.Fprintln(w, "# TYPE http_responses_total counter")
+	for i, class := range statusClasses {
+		fmt.Fprintf(w, "http_responses_total{class=%q} %d\n", class, classes[i])
+	}
+
+	fmt.Fprintln(w, "# HELP http_request_duration_seconds Time to serve a request.")
+	fmt.Fprintln(w, "# TYPE http_request_duration_seconds histogram")
+	for i, le := range metricBuckets {
+		fmt.Fprintf(w, "http_request_duration_seconds_bucket{le=%q} %d\n", strconv.FormatFloat(le, 'g', -1, 64), buckets[i])
+	}
+	fmt.Fprintf(w, "http_request_duration_seconds_bucket{le=\"+Inf\"} %d\n", requests)
+	fmt.Fprintf(w, "http_request_duration_seconds_sum %s\n", strconv.FormatFloat(sum, 'g', -1, 64))
+	fmt.Fprintf(w, "http_request_duration_seconds_count %d\n", requests)
+}
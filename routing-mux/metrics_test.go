@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsCountRequests(t *testing.T) {
+	m := newMetrics()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(http.ResponseWriter, *http.Request) {})
+	mux.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+	h := m.middleware(mux)
+	for _, path := range []string{"/ok", "/ok", "/missing", "/fail"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	rec := httptest.NewRecorder()
+	m.serveHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want the Prometheus text format", ct)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"\nhttp_requests_total 4\n",
+		`http_responses_total{class="2xx"} 2`,
+		`http_responses_total{class="3xx"} 0`,
+		`http_responses_total{class="4xx"} 1`,
+		`http_responses_total{class="5xx"} 1`,
+		`http_request_duration_seconds_bucket{le="+Inf"} 4`,
+		"http_request_duration_seconds_count 4\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics lacks %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestMetricsHistogramIsCumulative(t *testing.T) {
+	m := newMetrics()
+	m.observe(http.StatusOK, 3*time.Millisecond)
+	m.observe(http.StatusOK, 200*time.Millisecond)
+	m.observe(http.StatusOK, 20*time.Second)
+
+	rec := httptest.NewRecorder()
+	m.serveHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`http_request_duration_seconds_bucket{le="0.005"} 1`,
+		`http_request_duration_seconds_bucket{le="0.1"} 1`,
+		`http_request_duration_seconds_bucket{le="0.25"} 2`,
+		`http_request_duration_seconds_bucket{le="10"} 2`,
+		`http_request_duration_seconds_bucket{le="+Inf"} 3`,
+		"http_request_duration_seconds_sum 20.203\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics lacks %q:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"golang/web"
+)
+
+// probeMethods are tried when building the Allow header of a 405.
+var probeMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// matchesRoute reports whether req reaches a route of router. It can't
+// just use the result of Match, which is true for any request once
+// NotFoundHandler is set.
+func matchesRoute(router *mux.Router, req *http.Request) bool {
+	var m mux.RouteMatch
+	return router.Match(req, &m) && m.MatchErr == nil
+}
+
+// notFound answers unmatched paths with a JSON 404.
+func notFound(w http.ResponseWriter, r *http.Request) {
+	web.WriteError(w, http.StatusNotFound, "no route for "+r.URL.Path)
+}
+
+// methodNotAllowed answers with a JSON 405 whose Allow header lists the
+// methods the path does have routes for.
+func methodNotAllowed(router *mux.Router) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var allowed []string
+		for _, m := range probeMethods {
+			probe := r.Clone(r.Context())
+			probe.Method = m
+			if matchesRoute(router, probe) {
+				allowed = append(allowed, m)
+			}
+		}
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		web.WriteError(w, http.StatusMethodNotAllowed, r.Method+" is not allowed on "+r.URL.Path)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNotFoundAndMethodNotAllowed(t *testing.T) {
+	r := bookRouter()
+	r.NotFoundHandler = http.HandlerFunc(notFound)
+	r.MethodNotAllowedHandler = methodNotAllowed(r)
+
+	tests := []struct {
+		method, target string
+		want           int
+		allow          string
+		errPrefix      string
+	}{
+		{http.MethodGet, "/no/such/path", http.StatusNotFound, "", "no route for /no/such/path"},
+		{http.MethodPatch, "/books/dune", http.StatusMethodNotAllowed, "GET, POST, PUT, DELETE", "PATCH is not allowed"},
+		{http.MethodPut, "/books/dune/page/1", http.StatusMethodNotAllowed, "GET", "PUT is not allowed"},
+	}
+	for _, tt := range tests {
+		rec := doBook(r, tt.method, tt.target, "")
+		if rec.Code != tt.want {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.target, rec.Code, tt.want)
+			continue
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s %s: Content-Type = %q, want JSON", tt.method, tt.target, ct)
+		}
+		var body struct{ Error string }
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || !strings.HasPrefix(body.Error, tt.errPrefix) {
+			t.Errorf("%s %s: body = %s, want error %q", tt.method, tt.target, rec.Body, tt.errPrefix)
+		}
+		if allow := rec.Header().Get("Allow"); allow != tt.allow {
+			t.Errorf("%s %s: Allow = %q, want %q", tt.method, tt.target, allow, tt.allow)
+		}
+	}
+}
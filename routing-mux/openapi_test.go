@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// muxPattern matches the regexp of a mux path variable, as in
+// "{page:[0-9]+}", which OpenAPI spells "{page}".
+var muxPattern = regexp.MustCompile(`\{(\w+):[^}]*\}`)
+
+func TestOpenAPIListsEveryBookRoute(t *testing.T) {
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("openapi.json: %v", err)
+	}
+
+	noop := func(h http.Handler) http.Handler { return h }
+	r := mux.NewRouter()
+	RegisterRoutes(r, newBookStore().routes(noop, noop))
+	err := r.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tmpl, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return err
+		}
+		path := muxPattern.ReplaceAllString(tmpl, "{$1}")
+		for _, m := range methods {
+			if _, ok := spec.Paths[path][strings.ToLower(m)]; !ok {
+				t.Errorf("%s %s is registered but missing from openapi.json", m, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	rec := httptest.NewRecorder()
+	serveOpenAPI(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil || !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("body isn't an OpenAPI 3 document: openapi=%q, %v", doc.OpenAPI, err)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	database "golang/MySQL-Database"
+)
+
+// pageParams reads ?page= (1-based) and ?per_page= from r, falling back to
+// the first page of database.DefaultPageSize items.
+func pageParams(r *http.Request) (page, perPage int) {
+	q := r.URL.Query()
+	page, err := strconv.Atoi(q.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	perPage, err = strconv.Atoi(q.Get("per_page"))
+	if err != nil || perPage < 1 || perPage > database.MaxPageSize {
+		perPage = database.DefaultPageSize
+	}
+	return page, perPage
+}
+
+// setPageLinks adds an RFC 5988 Link header with first, prev and next
+// relations for the current page. The URLs keep the rest of the query.
+func setPageLinks(w http.ResponseWriter, r *http.Request, page, perPage int, hasMore bool) {
+	link := func(p int, rel string) string {
+		q := r.URL.Query()
+		q.Set("page", strconv.Itoa(p))
+		q.Set("per_page", strconv.Itoa(perPage))
+		u := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+		return fmt.Sprintf(`<%s>; rel="%s"`, u.String(), rel)
+	}
+
+	links := []string{link(1, "first")}
+	if page > 1 {
+		links = append(links, link(page-1, "prev"))
+	}
+	if hasMore {
+		links = append(links, link(page+1, "next"))
+	}
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestSetPageLinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		page    int
+		hasMore bool
+		want    string
+	}{
+		{"first", 1, true,
+			`</users?page=1&per_page=10&q=x>; rel="first", </users?page=2&per_page=10&q=x>; rel="next"`},
+		{"middle", 3, true,
+			`</users?page=1&per_page=10&q=x>; rel="first", </users?page=2&per_page=10&q=x>; rel="prev", </users?page=4&per_page=10&q=x>; rel="next"`},
+		{"last", 5, false,
+			`</users?page=1&per_page=10&q=x>; rel="first", </users?page=4&per_page=10&q=x>; rel="prev"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/users?q=x&page=9", nil)
+			setPageLinks(rec, r, tt.page, 10, tt.hasMore)
+			if got := rec.Header().Get("Link"); got != tt.want {
+				t.Errorf("Link =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListUsersSetsLinkHeader(t *testing.T) {
+	db, mock := newPingMock(t)
+	now := time.Now()
+	// per_page 2 asks for 3 rows; getting all 3 means there is a next page.
+	mock.ExpectQuery("FROM users WHERE deleted_at IS NULL ORDER BY id LIMIT").WithArgs(3, 2).
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(3, "c", "h", "", now).
+			AddRow(4, "d", "h", "", now).
+			AddRow(5, "e", "h", "", now))
+
+	users := &userHandler{db: db}
+	rec := httptest.NewRecorder()
+	users.list(rec, httptest.NewRequest(http.MethodGet, "/users?page=2&per_page=2", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	want := `</users?page=1&per_page=2>; rel="first", </users?page=1&per_page=2>; rel="prev", </users?page=3&per_page=2>; rel="next"`
+	if got := rec.Header().Get("Link"); got != want {
+		t.Errorf("Link =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// A route such as /users/{id} may also be requested as /users/7?id=9.
+// Handlers read values through pathVar or queryParam so the source is
+// explicit: neither falls back to the other, and a query parameter can
+// never override a path variable or stand in for a missing one.
+
+// pathVar returns the {name} variable of the matched route, or "" if the
+// route has none.
+func pathVar(r *http.Request, name string) string {
+	return mux.Vars(r)[name]
+}
+
+// queryParam returns the first value of the name query parameter, or "".
+// Path variables of the same name are ignored.
+func queryParam(r *http.Request, name string) string {
+	return r.URL.Query().Get(name)
+}
+
+// atoiVar parses the name path variable as a positive integer. Routes
+// should also constrain the variable, e.g. {page:[0-9]+}, so that junk
+// never matches; atoiVar still rejects 0 and values that overflow an int.
+func atoiVar(vars map[string]string, name string) (int, error) {
+	n, err := strconv.Atoi(vars[name])
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%s must be a positive integer, got %q", name, vars[name])
+	}
+	return n, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestPathVarAndQueryParamSameName(t *testing.T) {
+	r := mux.NewRouter()
+	r.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "path=%s query=%s", pathVar(r, "id"), queryParam(r, "id"))
+	})
+	r.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "path=%s query=%s", pathVar(r, "id"), queryParam(r, "id"))
+	})
+
+	tests := []struct {
+		target, want string
+	}{
+		{"/users/7?id=9", "path=7 query=9"},
+		{"/users/7", "path=7 query="},
+		// The query can't stand in for a path variable the route lacks.
+		{"/users?id=9", "path= query=9"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if got := rec.Body.String(); got != tt.want {
+			t.Errorf("GET %s: %s, want %s", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestBookPageParam(t *testing.T) {
+	h := bookRouter()
+	tests := []struct {
+		target string
+		want   int
+		body   string
+	}{
+		{"/books/dune/page/12", http.StatusOK, "You've requested the book: dune on page 12\n"},
+		{"/books/dune/page/0", http.StatusBadRequest, "page must be a positive integer"},
+		{"/books/dune/page/99999999999999999999", http.StatusBadRequest, "page must be a positive integer"},
+		// The {page:[0-9]+} constraint keeps junk from matching at all.
+		{"/books/dune/page/abc", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		rec := doBook(h, http.MethodGet, tt.target, "")
+		if rec.Code != tt.want || !strings.Contains(rec.Body.String(), tt.body) {
+			t.Errorf("GET %s = %d %q, want %d %q", tt.target, rec.Code, rec.Body, tt.want, tt.body)
+		}
+	}
+}
+
+func TestAtoiVar(t *testing.T) {
+	if n, err := atoiVar(map[string]string{"page": "3"}, "page"); n != 3 || err != nil {
+		t.Errorf("atoiVar(3) = %d, %v", n, err)
+	}
+	for _, v := range []string{"", "abc", "-1", "0", "1.5"} {
+		if _, err := atoiVar(map[string]string{"page": v}, "page"); err == nil {
+			t.Errorf("atoiVar(%q) succeeded", v)
+		}
+	}
+}
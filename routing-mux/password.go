@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang/web"
+)
+
+// Names of the rules a password can fail.
+const (
+	ruleMinLength = "min_length"
+	ruleUpper     = "uppercase"
+	ruleLower     = "lowercase"
+	ruleDigit     = "digit"
+	ruleSymbol    = "symbol"
+	ruleCommon    = "common_password"
+)
+
+// commonPasswords is a short blacklist of the passwords seen most often in
+// breach dumps. Entries are lower case.
+var commonPasswords = map[string]bool{
+	"123456": true, "123456789": true, "12345678": true, "password": true,
+	"qwerty": true, "qwerty123": true, "1q2w3e4r": true, "111111": true,
+	"iloveyou": true, "admin": true, "welcome": true, "letmein": true,
+	"monkey": true, "dragon": true, "football": true, "sunshine": true,
+	"password1": true, "password123": true, "abc123": true, "passw0rd": true,
+}
+
+// passwordPolicy describes what a password must satisfy at signup and
+// password reset.
+type passwordPolicy struct {
+	MinLength     int             `json:"min_length"`
+	RequireUpper  bool            `json:"require_upper"`
+	RequireLower  bool            `json:"require_lower"`
+	RequireDigit  bool            `json:"require_digit"`
+	RequireSymbol bool            `json:"require_symbol"`
+	Blacklist     map[string]bool `json:"-"`
+}
+
+// passwordReport is the outcome of checking a password. Score runs from 0
+// (unusable) to 4.
+type passwordReport struct {
+	OK     bool     `json:"ok"`
+	Score  int      `json:"score"`
+	Failed []string `json:"failed"`
+}
+
+func (p passwordPolicy) evaluate(password string) passwordReport {
+	var upper, lower, digit, symbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsLower(c):
+			lower = true
+		case unicode.IsDigit(c):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	length := utf8.RuneCountInString(password)
+
+	failed := []string{}
+	check := func(required, ok bool, rule string) {
+		if required && !ok {
+			failed = append(failed, rule)
+		}
+	}
+	check(true, length >= p.MinLength, ruleMinLength)
+	check(p.RequireUpper, upper, ruleUpper)
+	check(p.RequireLower, lower, ruleLower)
+	check(p.RequireDigit, digit, ruleDigit)
+	check(p.RequireSymbol, symbol, ruleSymbol)
+	common := p.Blacklist[strings.ToLower(password)]
+	check(true, !common, ruleCommon)
+
+	score := 0
+	if !common {
+		for _, has := range []bool{upper, lower, digit, symbol} {
+			if has {
+				score++
+			}
+		}
+		if length < p.MinLength {
+			score /= 2
+		} else if length >= 2*p.MinLength && score < 4 {
+			score++
+		}
+	}
+	return passwordReport{OK: len(failed) == 0, Score: score, Failed: failed}
+}
+
+// strengthHandler serves POST /auth/password-strength with a
+// {"password": "..."} body and answers with the passwordReport.
+func (p passwordPolicy) strengthHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := web.DecodeJSON(r, &req); err != nil {
+		web.WriteError(w, web.DecodeStatus(err), err.Error())
+		return
+	}
+	web.WriteJSON(w, http.StatusOK, p.evaluate(req.Password))
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang/web"
+)
+
+var strictPolicy = passwordPolicy{
+	MinLength:     10,
+	RequireUpper:  true,
+	RequireLower:  true,
+	RequireDigit:  true,
+	RequireSymbol: true,
+	Blacklist:     commonPasswords,
+}
+
+// checkStrength posts password to the strength endpoint.
+func checkStrength(t *testing.T, password string) passwordReport {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"password": password})
+	rec := httptest.NewRecorder()
+	strictPolicy.strengthHandler(rec, httptest.NewRequest(http.MethodPost, "/auth/password-strength", strings.NewReader(string(body))))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var rep passwordReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &rep); err != nil {
+		t.Fatal(err)
+	}
+	return rep
+}
+
+func TestPasswordStrengthStrong(t *testing.T) {
+	rep := checkStrength(t, "Tr0ub4dor&3-horse")
+	if !rep.OK || len(rep.Failed) != 0 || rep.Score != 4 {
+		t.Errorf("report = %+v, want ok with score 4", rep)
+	}
+}
+
+func TestPasswordStrengthWeak(t *testing.T) {
+	tests := []struct {
+		password string
+		failed   []string
+	}{
+		{"short", []string{ruleMinLength, ruleUpper, ruleDigit, ruleSymbol}},
+		{"alllowercaseletters", []string{ruleUpper, ruleDigit, ruleSymbol}},
+		{"Password123", []string{ruleSymbol, ruleCommon}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			rep := checkStrength(t, tt.password)
+			if rep.OK {
+				t.Error("weak password reported ok")
+			}
+			if !reflect.DeepEqual(rep.Failed, tt.failed) {
+				t.Errorf("failed = %q, want %q", rep.Failed, tt.failed)
+			}
+		})
+	}
+}
+
+func TestPasswordStrengthBadBody(t *testing.T) {
+	rec := httptest.NewRecorder()
+	strictPolicy.strengthHandler(rec, httptest.NewRequest(http.MethodPost, "/auth/password-strength", strings.NewReader("{")))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
+
+func TestPasswordStrengthOversizedBody(t *testing.T) {
+	body := `{"password":"` + strings.Repeat("a", web.MaxJSONBody) + `"}`
+	rec := httptest.NewRecorder()
+	strictPolicy.strengthHandler(rec, httptest.NewRequest(http.MethodPost, "/auth/password-strength", strings.NewReader(body)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want 413", rec.Code)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"golang/web"
+)
+
+// limitPathSegments rejects requests whose path has more than max
+// segments with 400. It wraps the router so pathologically deep paths
+// never reach route matching. Only the path counts; the query string is
+// not part of r.URL.Path.
+func limitPathSegments(max int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if countSegments(r.URL.Path) > max {
+				web.WriteError(w, http.StatusBadRequest, "too many path segments")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func countSegments(path string) int {
+	n := 0
+	for _, seg := range strings.Split(path, "/") {
+		if seg != "" {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLimitPathSegments(t *testing.T) {
+	h := limitPathSegments(4)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	tests := []struct {
+		name   string
+		target string
+		want   int
+	}{
+		{"normal path", "/books/go/page/3", http.StatusOK},
+		{"query does not count", "/books/go?a=/x/y/z/w/v&b=1", http.StatusOK},
+		{"too deep", "/" + strings.Repeat("a/", 5), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if rec.Code != tt.want {
+				t.Errorf("%s: status = %d, want %d", tt.target, rec.Code, tt.want)
+			}
+		})
+	}
+}
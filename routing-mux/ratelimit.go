@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"golang/web"
+)
+
+// rateLimiter gives every client IP its own token bucket refilled at
+// perSec tokens per second up to burst. Buckets untouched for idle are
+// dropped by sweep so the map doesn't grow with every address ever seen.
+type rateLimiter struct {
+	perSec rate.Limit
+	burst  int
+	idle   time.Duration
+	// trustForwarded takes the client IP from X-Forwarded-For. Only set it
+	// behind a proxy that appends the real address, or clients can pick
+	// their own bucket.
+	trustForwarded bool
+
+	mu      sync.Mutex
+	clients map[string]*rateClient
+}
+
+type rateClient struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newRateLimiter(perSec float64, burst int, trustForwarded bool) *rateLimiter {
+	return &rateLimiter{
+		perSec:         rate.Limit(perSec),
+		burst:          burst,
+		idle:           10 * time.Minute,
+		trustForwarded: trustForwarded,
+		clients:        make(map[string]*rateClient),
+	}
+}
+
+func (rl *rateLimiter) limiter(ip string, now time.Time) *rate.Limiter {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	c, ok := rl.clients[ip]
+	if !ok {
+		c = &rateClient{limiter: rate.NewLimiter(rl.perSec, rl.burst)}
+		rl.clients[ip] = c
+	}
+	c.lastSeen = now
+	return c.limiter
+}
+
+// sweep drops the buckets of clients not seen since now-idle. A client
+// that comes back starts with a full bucket, which is what an idle bucket
+// would have refilled to anyway.
+func (rl *rateLimiter) sweep(now time.Time) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	for ip, c := range rl.clients {
+		if now.Sub(c.lastSeen) > rl.idle {
+			delete(rl.clients, ip)
+		}
+	}
+}
+
+// sweepEvery runs sweep on every tick until ctx is done.
+func (rl *rateLimiter) sweepEvery(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case now := <-t.C:
+			rl.sweep(now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// middleware answers 429 with Retry-After once the client's bucket is
+// empty. Health-check probes are limited like everything else: they are
+// recognized partly by User-Agent, which any client can send.
+func (rl *rateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		res := rl.limiter(clientIP(r, rl.trustForwarded), now).ReserveN(now, 1)
+		if delay := res.DelayFrom(now); !res.OK() || delay > 0 {
+			res.CancelAt(now)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			web.WriteError(w, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP is the host part of RemoteAddr or, with trustForwarded, the
+// last X-Forwarded-For entry: the one added by the proxy in front of us.
+// Earlier entries are whatever the client chose to send.
+func clientIP(r *http.Request, trustForwarded bool) string {
+	if trustForwarded {
+		if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+			hops := strings.Split(xff[len(xff)-1], ",")
+			if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func limitedGet(h http.Handler, remote string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/books/dune", nil)
+	req.RemoteAddr = remote
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimitRejectsRequestOverBurst(t *testing.T) {
+	const burst = 5
+	h := newRateLimiter(1, burst, false).middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i := 0; i < burst; i++ {
+		if rec := limitedGet(h, "10.0.0.1:5000", nil); rec.Code != http.StatusOK {
+			t.Fatalf("request %d = %d, want 200", i+1, rec.Code)
+		}
+	}
+	rec := limitedGet(h, "10.0.0.1:5001", nil)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("request %d = %d, want 429", burst+1, rec.Code)
+	}
+	if s, err := strconv.Atoi(rec.Header().Get("Retry-After")); err != nil || s < 1 {
+		t.Errorf("Retry-After = %q, want a positive number of seconds", rec.Header().Get("Retry-After"))
+	}
+
+	if rec := limitedGet(h, "10.0.0.2:5000", nil); rec.Code != http.StatusOK {
+		t.Errorf("another client = %d, want 200", rec.Code)
+	}
+}
+
+func TestRateLimitAppliesToProbeUserAgents(t *testing.T) {
+	const burst = 3
+	hc := newHealthChecks([]string{"/healthz"}, []string{"kube-probe"}, false)
+	h := hc.middleware(newRateLimiter(1, burst, false).middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	probe := http.Header{"User-Agent": {"kube-probe/1.29"}}
+
+	for i := 0; i < burst; i++ {
+		if rec := limitedGet(h, "10.0.0.1:5000", probe); rec.Code != http.StatusOK {
+			t.Fatalf("request %d = %d, want 200", i+1, rec.Code)
+		}
+	}
+	if rec := limitedGet(h, "10.0.0.1:5000", probe); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("request %d with a probe User-Agent = %d, want 429", burst+1, rec.Code)
+	}
+}
+
+func TestRateLimitForwardedFor(t *testing.T) {
+	h := newRateLimiter(1, 1, true).middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	proxy := "192.0.2.1:443"
+
+	if rec := limitedGet(h, proxy, http.Header{"X-Forwarded-For": {"10.0.0.1"}}); rec.Code != http.StatusOK {
+		t.Fatalf("first client = %d, want 200", rec.Code)
+	}
+	// A spoofed first hop doesn't buy a fresh bucket; the proxy's entry counts.
+	if rec := limitedGet(h, proxy, http.Header{"X-Forwarded-For": {"1.2.3.4, 10.0.0.1"}}); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("spoofed hop = %d, want 429", rec.Code)
+	}
+	if rec := limitedGet(h, proxy, http.Header{"X-Forwarded-For": {"10.0.0.2"}}); rec.Code != http.StatusOK {
+		t.Errorf("second client behind the proxy = %d, want 200", rec.Code)
+	}
+}
+
+func TestRateLimitSweepDropsIdleClients(t *testing.T) {
+	rl := newRateLimiter(1, 1, false)
+	now := time.Now()
+	rl.limiter("10.0.0.1", now.Add(-time.Hour))
+	rl.limiter("10.0.0.2", now)
+
+	rl.sweep(now)
+	if _, ok := rl.clients["10.0.0.1"]; ok {
+		t.Error("idle client was kept")
+	}
+	if _, ok := rl.clients["10.0.0.2"]; !ok {
+		t.Error("active client was dropped")
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"golang/web"
+)
+
+// RecoverMiddleware turns a panic in a handler into a logged stack trace
+// and a 500 JSON error, instead of net/http dropping the connection. If
+// the handler had already started the response, the status can no longer
+// change; the client gets whatever was written and the connection is
+// closed as usual. http.ErrAbortHandler is left to net/http. The log line
+// includes the request body snapshot from logBodies, if any.
+func RecoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Headers set by outer middleware, such as X-Request-ID and CORS,
+		// belong on the error response too; whatever the handler added
+		// doesn't.
+		header := w.Header().Clone()
+		rec := newStatusRecorder(w)
+		r, body := withBodySnapshot(r)
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			log.Printf("request_id=%s panic serving %s %s body=%q: %v\n%s",
+				RequestIDFromContext(r.Context()), r.Method, r.URL.Path, *body, p, debug.Stack())
+			if rec.wroteHeader {
+				panic(http.ErrAbortHandler)
+			}
+			h := w.Header()
+			for k := range h {
+				delete(h, k)
+			}
+			for k, v := range header {
+				h[k] = v
+			}
+			web.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecoverMiddlewareAnswers500(t *testing.T) {
+	logs := captureLog(t)
+	outer := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Request-ID", "req-1")
+			next.ServeHTTP(w, r)
+		})
+	}
+	srv := httptest.NewServer(outer(RecoverMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		panic("nil map")
+	}))))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/boom")
+	if err != nil {
+		t.Fatalf("GET: %v, want a 500 rather than a dropped connection", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusInternalServerError || !strings.Contains(string(body), `"error":"internal server error"`) {
+		t.Errorf("got %d %s, want a 500 JSON error", resp.StatusCode, body)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want the handler's header replaced", ct)
+	}
+	if id := resp.Header.Get("X-Request-ID"); id != "req-1" {
+		t.Errorf("X-Request-ID = %q, want the outer middleware's header kept", id)
+	}
+	if l := logs.String(); !strings.Contains(l, "panic serving GET /boom") || !strings.Contains(l, "nil map") || !strings.Contains(l, "goroutine") {
+		t.Errorf("log = %q, want the panic with its stack", l)
+	}
+}
+
+func TestRecoverMiddlewareAfterWrite(t *testing.T) {
+	captureLog(t)
+	srv := httptest.NewServer(RecoverMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, "partial")
+		panic("halfway")
+	})))
+	defer srv.Close()
+
+	// The status is already on the wire, so the response is cut off
+	// instead of being followed by a second one.
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if _, err := io.ReadAll(resp.Body); err == nil {
+		t.Error("response completed normally after a panic mid-write")
+	}
+}
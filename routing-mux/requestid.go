@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+)
+
+type requestIDKey struct{}
+type correlationIDKey struct{}
+
+// RequestIDMiddleware tags every request with an id, taken from
+// X-Request-ID when the client sent a usable one and generated otherwise,
+// and echoes it in the response.
+//
+// It also assigns a correlation id that groups related requests. When the
+// request carries an Idempotency-Key the correlation id is derived from the
+// key, so every retry of one operation shares it even though each attempt
+// gets its own request id; LoggingMiddleware logs it next to the request
+// id. Without a key the correlation id is just the request id.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		correlation := id
+		if key := r.Header.Get("Idempotency-Key"); key != "" {
+			correlation = idempotencyCorrelation(key)
+		}
+
+		w.Header().Set("X-Request-ID", id)
+		w.Header().Set("X-Correlation-ID", correlation)
+
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		ctx = context.WithValue(ctx, correlationIDKey{}, correlation)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequestIDFromContext returns the id assigned by RequestIDMiddleware.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// CorrelationIDFromContext returns the correlation id assigned by
+// RequestIDMiddleware.
+func CorrelationIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+// idempotencyCorrelation hashes the key rather than using it verbatim, so
+// client-chosen keys never end up in logs.
+func idempotencyCorrelation(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "idem-" + hex.EncodeToString(sum[:8])
+}
+
+// validRequestID accepts short ids made of printable ASCII, so a client
+// cannot inject newlines or huge values into the logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random (version 4) UUID.
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("request id: %v", err))
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var correlationField = regexp.MustCompile(`request_id=(\S+) correlation_id=(\S+)`)
+
+// loggedIDs serves a request with the given Idempotency-Key through the
+// request id and logging middleware and returns the ids from its log line.
+func loggedIDs(t *testing.T, key string) (requestID, correlationID string) {
+	t.Helper()
+	logs := captureLog(t)
+	h := NewChain(RequestIDMiddleware, LoggingMiddleware).Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest(http.MethodPost, "/users", nil)
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	m := correlationField.FindStringSubmatch(logs.String())
+	if m == nil {
+		t.Fatalf("no ids in log line: %s", logs)
+	}
+	return m[1], m[2]
+}
+
+func TestRetriesShareCorrelationID(t *testing.T) {
+	req1, corr1 := loggedIDs(t, "order-42")
+	req2, corr2 := loggedIDs(t, "order-42")
+
+	if req1 == req2 {
+		t.Errorf("both attempts got request id %s, want one each", req1)
+	}
+	if corr1 != corr2 {
+		t.Errorf("correlation ids %s and %s differ for the same Idempotency-Key", corr1, corr2)
+	}
+	if corr1 == "order-42" {
+		t.Error("correlation id is the raw Idempotency-Key")
+	}
+
+	if _, other := loggedIDs(t, "order-43"); other == corr1 {
+		t.Errorf("different keys share correlation id %s", other)
+	}
+}
+
+func TestCorrelationIDDefaultsToRequestID(t *testing.T) {
+	req, corr := loggedIDs(t, "")
+	if req != corr {
+		t.Errorf("correlation id = %s, want the request id %s", corr, req)
+	}
+}
+
+var uuidV4 = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+// serveRequestID runs a request with the given X-Request-ID through
+// RequestIDMiddleware and returns the id the handler saw and the response.
+func serveRequestID(header string) (string, *httptest.ResponseRecorder) {
+	var seen string
+	h := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFromContext(r.Context())
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/books/dune", nil)
+	if header != "" {
+		req.Header.Set("X-Request-ID", header)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return seen, rec
+}
+
+func TestRequestIDPreserved(t *testing.T) {
+	seen, rec := serveRequestID("edge-7f3a")
+	if seen != "edge-7f3a" || rec.Header().Get("X-Request-ID") != "edge-7f3a" {
+		t.Errorf("context id %q, response header %q; want the supplied edge-7f3a", seen, rec.Header().Get("X-Request-ID"))
+	}
+}
+
+func TestRequestIDGenerated(t *testing.T) {
+	for _, header := range []string{"", "has space", strings.Repeat("x", 129)} {
+		seen, rec := serveRequestID(header)
+		if !uuidV4.MatchString(seen) {
+			t.Errorf("X-Request-ID %q: generated id %q isn't a UUID", header, seen)
+		}
+		if got := rec.Header().Get("X-Request-ID"); got != seen {
+			t.Errorf("X-Request-ID %q: response header %q, want %q", header, got, seen)
+		}
+	}
+	first, _ := serveRequestID("")
+	second, _ := serveRequestID("")
+	if first == second {
+		t.Errorf("two requests got the same generated id %s", first)
+	}
+}
+
+func TestRequestIDLogged(t *testing.T) {
+	logs := captureLog(t)
+	req := httptest.NewRequest(http.MethodGet, "/books/dune", nil)
+	req.Header.Set("X-Request-ID", "edge-7f3a")
+	NewChain(RequestIDMiddleware, LoggingMiddleware).Then(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), req)
+
+	if !strings.Contains(logs.String(), "request_id=edge-7f3a ") {
+		t.Errorf("log line lacks the request id: %s", logs)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"golang/web"
+)
+
+// requireBody rejects POST, PUT and PATCH requests without a body with 400
+// before the handler sees them. A chunked body of unknown length is
+// checked by reading its first byte, which is then put back.
+func requireBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		empty := r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0
+		if !empty && r.ContentLength < 0 {
+			var first [1]byte
+			n, err := io.ReadFull(r.Body, first[:])
+			if n == 0 && err != io.EOF {
+				web.WriteError(w, http.StatusBadRequest, "could not read request body")
+				return
+			}
+			empty = n == 0
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(first[:n]), r.Body), r.Body}
+		}
+		if empty {
+			web.WriteError(w, http.StatusBadRequest, "request body is required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
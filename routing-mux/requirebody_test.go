@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequireBody(t *testing.T) {
+	var got string
+	h := requireBody(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got = string(b)
+	}))
+	tests := []struct {
+		name    string
+		method  string
+		body    io.Reader
+		chunked bool
+		want    int
+	}{
+		{"post with body", http.MethodPost, strings.NewReader(`{"a":1}`), false, http.StatusOK},
+		{"post without body", http.MethodPost, nil, false, http.StatusBadRequest},
+		{"put with empty body", http.MethodPut, strings.NewReader(""), false, http.StatusBadRequest},
+		{"chunked patch with body", http.MethodPatch, strings.NewReader(`{"a":1}`), true, http.StatusOK},
+		{"chunked patch without body", http.MethodPatch, strings.NewReader(""), true, http.StatusBadRequest},
+		{"get without body", http.MethodGet, nil, false, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = ""
+			req := httptest.NewRequest(tt.method, "/books/dune", tt.body)
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if tt.want == http.StatusOK && tt.body != nil && got != `{"a":1}` {
+				t.Errorf("handler read %q, want the whole body", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Route describes one endpoint for RegisterRoutes.
+type Route struct {
+	Method  string
+	Path    string
+	Handler http.Handler
+	// Middlewares wrap Handler for this route only, outermost first. They
+	// run inside the router's own middleware.
+	Middlewares []func(http.Handler) http.Handler
+	// Name, if set, names the route for URL building.
+	Name string
+}
+
+// RegisterRoutes adds routes to r in order. Order matters as with
+// hand-registered routes: mux uses the first route that matches.
+func RegisterRoutes(r *mux.Router, routes []Route) {
+	for _, rt := range routes {
+		route := r.Handle(rt.Path, NewChain(rt.Middlewares...).Then(rt.Handler)).Methods(rt.Method)
+		if rt.Name != "" {
+			route.Name(rt.Name)
+		}
+	}
+}
+
+// middlewares is shorthand for a Route's Middlewares in route tables.
+func middlewares(mw ...func(http.Handler) http.Handler) []func(http.Handler) http.Handler {
+	return mw
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// namedHandler is a comparable handler, so a match can be checked by
+// identity.
+type namedHandler string
+
+func (h namedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(h))
+}
+
+func TestRegisterRoutesResolves(t *testing.T) {
+	tagged := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Route-Middleware", "yes")
+			next.ServeHTTP(w, r)
+		})
+	}
+	r := mux.NewRouter()
+	RegisterRoutes(r, []Route{
+		{Method: http.MethodGet, Path: "/items", Handler: namedHandler("list")},
+		{Method: http.MethodPost, Path: "/items", Handler: namedHandler("create"), Middlewares: middlewares(tagged)},
+		{Method: http.MethodGet, Path: "/items/{id:[0-9]+}", Handler: namedHandler("get"), Name: "item"},
+	})
+
+	tests := []struct {
+		method, path string
+		want         http.Handler
+	}{
+		{http.MethodGet, "/items", namedHandler("list")},
+		{http.MethodGet, "/items/42", namedHandler("get")},
+	}
+	for _, tt := range tests {
+		var m mux.RouteMatch
+		if !r.Match(httptest.NewRequest(tt.method, tt.path, nil), &m) || m.Handler != tt.want {
+			t.Errorf("%s %s matched %v, want %v", tt.method, tt.path, m.Handler, tt.want)
+		}
+	}
+
+	var m mux.RouteMatch
+	if r.Match(httptest.NewRequest(http.MethodGet, "/items/abc", nil), &m) {
+		t.Errorf("GET /items/abc matched %v, want no route", m.Handler)
+	}
+	m = mux.RouteMatch{}
+	if r.Match(httptest.NewRequest(http.MethodDelete, "/items", nil), &m); m.MatchErr != mux.ErrMethodMismatch {
+		t.Errorf("DELETE /items: MatchErr = %v, want a method mismatch", m.MatchErr)
+	}
+
+	// The middleware wraps only its own route.
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/items", nil))
+	if rec.Body.String() != "create" || rec.Header().Get("X-Route-Middleware") != "yes" {
+		t.Errorf("POST /items = %q with header %q, want create behind the middleware", rec.Body, rec.Header().Get("X-Route-Middleware"))
+	}
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items", nil))
+	if rec.Header().Get("X-Route-Middleware") != "" {
+		t.Error("GET /items ran another route's middleware")
+	}
+
+	if u, err := r.Get("item").URL("id", "7"); err != nil || u.Path != "/items/7" {
+		t.Errorf("URL for item 7 = %v, %v; want /items/7", u, err)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	database "golang/MySQL-Database"
+	"golang/web"
+)
+
+const sessionCookieName = "session"
+
+type sessionUserKey struct{}
+
+// SessionStore creates and checks the tokens carried in the session
+// cookie. cookieSessions keeps everything in the signed token;
+// database.MySQLSessionStore keeps sessions server-side, so they can be
+// revoked and are shared by every instance.
+type SessionStore interface {
+	// Create starts a session for userID.
+	Create(ctx context.Context, userID int) (token string, expires time.Time, err error)
+	// Get returns the user of a valid, unexpired session. ok is false for
+	// an unknown, forged or expired token; err is for store failures.
+	Get(ctx context.Context, token string) (userID int, ok bool, err error)
+	// Delete ends the session, where the store can.
+	Delete(ctx context.Context, token string) error
+}
+
+// cookieSessions keeps the session in the cookie itself: the user id and
+// expiry, followed by an HMAC-SHA256 of both under key. Without the key a
+// client can read the cookie but not forge or extend one. The flip side is
+// that a session can't be revoked before it expires, except by changing
+// the key, which ends everyone's; Delete does nothing.
+type cookieSessions struct {
+	key []byte
+	ttl time.Duration
+}
+
+// newCookieSessions signs with key, or with a random key if it is empty.
+func newCookieSessions(key string, ttl time.Duration) (*cookieSessions, error) {
+	k := []byte(key)
+	if len(k) == 0 {
+		k = make([]byte, 32)
+		if _, err := rand.Read(k); err != nil {
+			return nil, err
+		}
+		log.Print("SESSION_KEY not set, using a random key; sessions end on restart")
+	}
+	return &cookieSessions{key: k, ttl: ttl}, nil
+}
+
+func (s *cookieSessions) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s *cookieSessions) Create(ctx context.Context, userID int) (string, time.Time, error) {
+	expires := time.Now().Add(s.ttl)
+	payload := strconv.Itoa(userID) + "|" + strconv.FormatInt(expires.Unix(), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + s.sign(payload), expires, nil
+}
+
+func (s *cookieSessions) Get(ctx context.Context, token string) (int, bool, error) {
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return 0, false, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0, false, nil
+	}
+	payload := string(raw)
+	if !hmac.Equal([]byte(sig), []byte(s.sign(payload))) {
+		return 0, false, nil
+	}
+
+	id, exp, ok := strings.Cut(payload, "|")
+	if !ok {
+		return 0, false, nil
+	}
+	userID, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, false, nil
+	}
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || time.Now().After(time.Unix(unix, 0)) {
+		return 0, false, nil
+	}
+	return userID, true, nil
+}
+
+func (s *cookieSessions) Delete(ctx context.Context, token string) error {
+	return nil
+}
+
+// setSessionCookie hands token to the client until expires.
+func setSessionCookie(w http.ResponseWriter, r *http.Request, token string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func clearSessionCookie(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// sessionToken returns the token of the request's session cookie.
+func sessionToken(r *http.Request) (string, bool) {
+	c, err := r.Cookie(sessionCookieName)
+	if err != nil || c.Value == "" {
+		return "", false
+	}
+	return c.Value, true
+}
+
+// RequireSession redirects requests without a valid session in sessions
+// to /login with 302, passing the original URL as ?next=. A failing store
+// is answered with 500. Handlers behind it find the user id with
+// sessionUserID.
+func RequireSession(sessions SessionStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			varyOn(r, "Cookie")
+			var id int
+			token, ok := sessionToken(r)
+			if ok {
+				var err error
+				id, ok, err = sessions.Get(r.Context(), token)
+				if err != nil {
+					log.Printf("request_id=%s load session: %v", RequestIDFromContext(r.Context()), err)
+					web.WriteError(w, http.StatusInternalServerError, "could not load session")
+					return
+				}
+			}
+			if !ok {
+				http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionUserKey{}, id)))
+		})
+	}
+}
+
+// sessionUserID returns the user id RequireSession found for the request.
+func sessionUserID(ctx context.Context) (int, bool) {
+	id, ok := ctx.Value(sessionUserKey{}).(int)
+	return id, ok
+}
+
+// loginHandler checks the username and password form fields and starts a
+// session. Unknown usernames are compared against a dummy hash so they
+// take as long to reject as a wrong password.
+type loginHandler struct {
+	db       database.Querier
+	sessions SessionStore
+	dummy    string
+}
+
+func newLoginHandler(db database.Querier, sessions SessionStore) (*loginHandler, error) {
+	dummy, err := database.HashPassword("no such user")
+	if err != nil {
+		return nil, err
+	}
+	return &loginHandler{db: db, sessions: sessions, dummy: dummy}, nil
+}
+
+func (h *loginHandler) login(w http.ResponseWriter, r *http.Request) {
+	username, password := r.PostFormValue("username"), r.PostFormValue("password")
+	if username == "" || password == "" {
+		web.WriteError(w, http.StatusBadRequest, "username and password are required")
+		return
+	}
+
+	u, err := database.UserByUsername(r.Context(), h.db, username)
+	hash := u.Password
+	if errors.Is(err, database.ErrUserNotFound) {
+		hash = h.dummy
+	} else if err != nil {
+		log.Printf("request_id=%s login: %v", RequestIDFromContext(r.Context()), err)
+		web.WriteError(w, http.StatusInternalServerError, "could not log in")
+		return
+	}
+	if database.CheckPassword(hash, password) != nil || err != nil {
+		web.WriteError(w, http.StatusUnauthorized, "invalid username or password")
+		return
+	}
+
+	token, expires, err := h.sessions.Create(r.Context(), u.ID)
+	if err != nil {
+		log.Printf("request_id=%s login: create session: %v", RequestIDFromContext(r.Context()), err)
+		web.WriteError(w, http.StatusInternalServerError, "could not log in")
+		return
+	}
+	setSessionCookie(w, r, token, expires)
+	web.WriteJSON(w, http.StatusOK, u)
+}
+
+func (h *loginHandler) logout(w http.ResponseWriter, r *http.Request) {
+	if token, ok := sessionToken(r); ok {
+		if err := h.sessions.Delete(r.Context(), token); err != nil {
+			log.Printf("request_id=%s logout: %v", RequestIDFromContext(r.Context()), err)
+			web.WriteError(w, http.StatusInternalServerError, "could not log out")
+			return
+		}
+	}
+	clearSessionCookie(w, r)
+	w.WriteHeader(http.StatusNoContent)
+}
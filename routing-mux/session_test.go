@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"golang.org/x/crypto/bcrypt"
+
+	database "golang/MySQL-Database"
+)
+
+// sessionApp serves /login, /logout and a /me page behind RequireSession,
+// with cookie sessions signed by a fixed key.
+func sessionApp(t *testing.T) (http.Handler, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock := newPingMock(t)
+	sessions, err := newCookieSessions("test-key", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	login, err := newLoginHandler(db, sessions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", login.login)
+	mux.HandleFunc("/logout", login.logout)
+	mux.Handle("/me", RequireSession(sessions)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ := sessionUserID(r.Context())
+		fmt.Fprintf(w, "user %d", id)
+	})))
+	return mux, mock
+}
+
+func expectUser(t *testing.T, mock sqlmock.Sqlmock, username, password string) {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectQuery("FROM users WHERE username = ").WithArgs(username).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(7, username, string(hash), username+"@example.com", time.Now()))
+}
+
+func postLogin(h http.Handler, username, password string) *httptest.ResponseRecorder {
+	form := url.Values{"username": {username}, "password": {password}}
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func getMe(h http.Handler, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func sessionCookie(t *testing.T, rec *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == sessionCookieName {
+			return c
+		}
+	}
+	t.Fatalf("no session cookie in %v", rec.Header()["Set-Cookie"])
+	return nil
+}
+
+func TestLoginStartsSession(t *testing.T) {
+	h, mock := sessionApp(t)
+	expectUser(t, mock, "alice", "hunter2")
+
+	rec := postLogin(h, "alice", "hunter2")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login = %d %s, want 200", rec.Code, rec.Body)
+	}
+	c := sessionCookie(t, rec)
+	if !c.HttpOnly || c.SameSite != http.SameSiteLaxMode {
+		t.Errorf("cookie HttpOnly=%v SameSite=%v, want HttpOnly and Lax", c.HttpOnly, c.SameSite)
+	}
+
+	if me := getMe(h, c); me.Code != http.StatusOK || me.Body.String() != "user 7" {
+		t.Errorf("GET /me = %d %q, want user 7", me.Code, me.Body)
+	}
+}
+
+func TestLoginWrongPassword(t *testing.T) {
+	h, mock := sessionApp(t)
+	expectUser(t, mock, "alice", "hunter2")
+
+	rec := postLogin(h, "alice", "guess")
+	if rec.Code != http.StatusUnauthorized || len(rec.Result().Cookies()) != 0 {
+		t.Errorf("login = %d with cookies %v, want 401 and none", rec.Code, rec.Result().Cookies())
+	}
+}
+
+func TestRequireSessionRejectsForgedCookie(t *testing.T) {
+	h, mock := sessionApp(t)
+	expectUser(t, mock, "alice", "hunter2")
+	genuine := sessionCookie(t, postLogin(h, "alice", "hunter2"))
+
+	// Keep the signature but claim to be user 1.
+	_, sig, _ := strings.Cut(genuine.Value, ".")
+	payload := fmt.Sprintf("1|%d", time.Now().Add(time.Hour).Unix())
+	forged := base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + sig
+
+	for name, value := range map[string]string{
+		"forged payload": forged,
+		"unsigned":       base64.RawURLEncoding.EncodeToString([]byte(payload)),
+		"garbage":        "not-a-session",
+	} {
+		rec := getMe(h, &http.Cookie{Name: sessionCookieName, Value: value})
+		if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/login?next=%2Fme" {
+			t.Errorf("%s: GET /me = %d Location %q, want 302 to /login", name, rec.Code, rec.Header().Get("Location"))
+		}
+	}
+	if rec := getMe(h, nil); rec.Code != http.StatusFound {
+		t.Errorf("no cookie: GET /me = %d, want 302", rec.Code)
+	}
+}
+
+func TestLogoutClearsCookie(t *testing.T) {
+	h, mock := sessionApp(t)
+	expectUser(t, mock, "alice", "hunter2")
+	c := sessionCookie(t, postLogin(h, "alice", "hunter2"))
+
+	req := httptest.NewRequest(http.MethodPost, "/logout", nil)
+	req.AddCookie(c)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("logout = %d, want 204", rec.Code)
+	}
+	cleared := sessionCookie(t, rec)
+	if cleared.MaxAge >= 0 || cleared.Value != "" {
+		t.Errorf("logout cookie = %+v, want it expired and empty", cleared)
+	}
+}
+
+func TestRequireSessionWithMySQLStore(t *testing.T) {
+	db, mock := newPingMock(t)
+	store := &database.MySQLSessionStore{DB: db, TTL: time.Hour}
+	me := RequireSession(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ := sessionUserID(r.Context())
+		fmt.Fprintf(w, "user %d", id)
+	}))
+	mux := http.NewServeMux()
+	mux.Handle("/me", me)
+
+	mock.ExpectQuery(`SELECT user_id FROM sessions`).WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(7))
+	if rec := getMe(mux, &http.Cookie{Name: sessionCookieName, Value: "valid"}); rec.Code != http.StatusOK || rec.Body.String() != "user 7" {
+		t.Errorf("valid session: GET /me = %d %q, want user 7", rec.Code, rec.Body)
+	}
+
+	mock.ExpectQuery(`SELECT user_id FROM sessions`).WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+	if rec := getMe(mux, &http.Cookie{Name: sessionCookieName, Value: "expired"}); rec.Code != http.StatusFound {
+		t.Errorf("expired session: GET /me = %d, want 302 to /login", rec.Code)
+	}
+
+	captureLog(t)
+	mock.ExpectQuery(`SELECT user_id FROM sessions`).WillReturnError(errors.New("connection refused"))
+	if rec := getMe(mux, &http.Cookie{Name: sessionCookieName, Value: "valid"}); rec.Code != http.StatusInternalServerError {
+		t.Errorf("store down: GET /me = %d, want 500", rec.Code)
+	}
+}
+
+func TestRequireSessionUsesReservedConn(t *testing.T) {
+	db, mock := newPingMock(t)
+	db.SetMaxOpenConns(1)
+	store := &database.MySQLSessionStore{DB: db, TTL: time.Hour}
+	h := database.Backpressure(db, 100*time.Millisecond, time.Second)(RequireSession(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ := sessionUserID(r.Context())
+		fmt.Fprintf(w, "user %d", id)
+	})))
+
+	mock.ExpectQuery(`SELECT user_id FROM sessions`).WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(7))
+	// Backpressure holds the only connection; a lookup waiting for another
+	// would fail once the request times out.
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/me", nil).WithContext(ctx)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: "valid"})
+	rec := httptest.NewRecorder()
+	captureLog(t)
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "user 7" {
+		t.Errorf("GET /me with a pool of 1 = %d %q, want user 7", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"log"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang/web"
+)
+
+// The shed fraction moves by shedStep per window and never reaches 1, so
+// some requests always get through to show whether latency has recovered.
+const (
+	shedStep        = 0.1
+	maxShedFraction = 0.9
+)
+
+// loadShedder turns away a fraction of requests while the server is
+// overloaded. Latency is collected in windows; each window whose p99 is
+// above threshold sheds shedStep more of the traffic, and each one below
+// it sheds shedStep less.
+type loadShedder struct {
+	threshold time.Duration
+	window    time.Duration
+	now       func() time.Time
+
+	mu          sync.Mutex
+	hist        latencyHistogram
+	windowStart time.Time
+	fraction    float64
+}
+
+func newLoadShedder(threshold, window time.Duration) *loadShedder {
+	return &loadShedder{threshold: threshold, window: window, now: time.Now, windowStart: time.Now()}
+}
+
+// shed reports whether to turn the request away, closing the current
+// window first if it is over.
+func (s *loadShedder) shed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now := s.now(); now.Sub(s.windowStart) >= s.window {
+		s.adjust()
+		s.windowStart = now
+	}
+	return s.fraction > 0 && rand.Float64() < s.fraction
+}
+
+// adjust moves the fraction by one step from the closed window's p99 and
+// starts a new histogram. A window without requests counts as healthy.
+func (s *loadShedder) adjust() {
+	before := s.fraction
+	if s.hist.total > 0 && s.hist.quantile(0.99) > s.threshold {
+		s.fraction += shedStep
+		if s.fraction > maxShedFraction {
+			s.fraction = maxShedFraction
+		}
+	} else {
+		s.fraction -= shedStep
+		if s.fraction < 1e-9 {
+			s.fraction = 0
+		}
+	}
+	if s.fraction != before {
+		log.Printf("load shedding: p99=%s, now shedding %.0f%% of requests", s.hist.quantile(0.99), s.fraction*100)
+	}
+	s.hist = latencyHistogram{}
+}
+
+func (s *loadShedder) observe(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hist.observe(d)
+}
+
+// middleware answers 503 to the requests it sheds. Only the requests let
+// through are timed, so shed ones don't make latency look better.
+func (s *loadShedder) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.shed() {
+			w.Header().Set("Retry-After", "1")
+			web.WriteError(w, http.StatusServiceUnavailable, "server overloaded")
+			return
+		}
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		s.observe(time.Since(start))
+	})
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeClockShedder returns a shedder with a 100ms threshold and 1s windows
+// whose clock only moves through the returned advance.
+func fakeClockShedder() (s *loadShedder, advance func()) {
+	now := time.Unix(0, 0)
+	s = newLoadShedder(100*time.Millisecond, time.Second)
+	s.now = func() time.Time { return now }
+	s.windowStart = now
+	return s, func() { now = now.Add(time.Second) }
+}
+
+// window records latencies for one window and closes it.
+func window(s *loadShedder, advance func(), latency time.Duration) float64 {
+	for i := 0; i < 20; i++ {
+		s.observe(latency)
+	}
+	advance()
+	s.shed()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fraction
+}
+
+// shedCount serves n requests through s and counts the 503s.
+func shedCount(s *loadShedder, n int) int {
+	h := s.middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	shed := 0
+	for i := 0; i < n; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code == http.StatusServiceUnavailable {
+			shed++
+		}
+	}
+	return shed
+}
+
+func TestLoadShedderEngagesAndRecovers(t *testing.T) {
+	captureLog(t)
+	s, advance := fakeClockShedder()
+	if n := shedCount(s, 100); n != 0 {
+		t.Fatalf("shed %d requests before any overload, want none", n)
+	}
+
+	var fraction float64
+	for i := 1; i <= 12; i++ {
+		fraction = window(s, advance, 500*time.Millisecond)
+		if want := math.Min(float64(i)*shedStep, maxShedFraction); math.Abs(fraction-want) > 1e-9 {
+			t.Fatalf("after %d slow windows shedding %.2f, want %.2f", i, fraction, want)
+		}
+	}
+	// 90% shed: 500 requests all getting through, or all being shed, is
+	// practically impossible.
+	if n := shedCount(s, 500); n == 0 || n == 500 {
+		t.Errorf("shed %d of 500 requests at %.0f%%, want some but not all", n, fraction*100)
+	}
+
+	for i := 1; i <= 9; i++ {
+		fraction = window(s, advance, 5*time.Millisecond)
+	}
+	if fraction != 0 {
+		t.Fatalf("after recovering shedding %.2f, want 0", fraction)
+	}
+	if n := shedCount(s, 100); n != 0 {
+		t.Errorf("shed %d requests after latency dropped, want none", n)
+	}
+}
+
+func TestLoadShedderIdleWindowIsHealthy(t *testing.T) {
+	captureLog(t)
+	s, advance := fakeClockShedder()
+	window(s, advance, time.Second)
+	advance()
+	s.shed()
+	if s.fraction != 0 {
+		t.Errorf("shedding %.2f after an idle window, want 0", s.fraction)
+	}
+}
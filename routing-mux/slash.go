@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// strictSlash stands in for mux's StrictSlash(true), which is left off on
+// the router: that option redirects /users/ to /users with 301 for every
+// method, and clients replay a redirected POST as a GET without its body.
+// Here, when only the other spelling of the path (with or without the
+// trailing slash) has a route, GET and HEAD are redirected with 301 and
+// everything else with 308, which keeps the method and body.
+func strictSlash(router *mux.Router) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/" || matchesRoute(router, r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			alt := *r.URL
+			if strings.HasSuffix(alt.Path, "/") {
+				alt.Path = strings.TrimSuffix(alt.Path, "/")
+			} else {
+				alt.Path += "/"
+			}
+			alt.RawPath = ""
+			probe := r.Clone(r.Context())
+			probe.URL = &alt
+			if !matchesRoute(router, probe) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			target := alt.EscapedPath()
+			if alt.RawQuery != "" {
+				target += "?" + alt.RawQuery
+			}
+			code := http.StatusPermanentRedirect
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				code = http.StatusMovedPermanently
+			}
+			http.Redirect(w, r, target, code)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// echoRouter answers POST and GET /users with the method and body it got.
+func echoRouter() *mux.Router {
+	r := mux.NewRouter()
+	r.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		io.WriteString(w, r.Method+" "+string(body))
+	}).Methods(http.MethodGet, http.MethodPost)
+	return r
+}
+
+func TestStrictSlashRedirectCodes(t *testing.T) {
+	r := echoRouter()
+	h := strictSlash(r)(r)
+	tests := []struct {
+		method, target string
+		want           int
+		location       string
+	}{
+		{http.MethodPost, "/users/", http.StatusPermanentRedirect, "/users"},
+		{http.MethodGet, "/users/?page=2", http.StatusMovedPermanently, "/users?page=2"},
+		{http.MethodPost, "/users", http.StatusOK, ""},
+		{http.MethodPost, "/nowhere/", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, strings.NewReader(`{}`)))
+		if rec.Code != tt.want || rec.Header().Get("Location") != tt.location {
+			t.Errorf("%s %s = %d Location %q, want %d %q", tt.method, tt.target, rec.Code, rec.Header().Get("Location"), tt.want, tt.location)
+		}
+	}
+}
+
+func TestStrictSlashPostKeepsBody(t *testing.T) {
+	r := echoRouter()
+	srv := httptest.NewServer(strictSlash(r)(r))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/users/", "application/json", strings.NewReader(`{"username":"alice"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	got, _ := io.ReadAll(resp.Body)
+	if want := `POST {"username":"alice"}`; string(got) != want {
+		t.Errorf("after the redirect the handler got %q, want %q", got, want)
+	}
+	if resp.Request.URL.Path != "/users" {
+		t.Errorf("final path = %q, want /users", resp.Request.URL.Path)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/mux"
+
+	"golang/web"
+)
+
+var statusClasses = [...]string{"2xx", "3xx", "4xx", "5xx"}
+
+// statusStats tallies responses by status-code class for every route. It
+// is a cheap error-rate check, not a replacement for real metrics.
+type statusStats struct {
+	mu     sync.Mutex
+	counts map[string]*[len(statusClasses)]uint64
+}
+
+func newStatusStats() *statusStats {
+	return &statusStats{counts: make(map[string]*[len(statusClasses)]uint64)}
+}
+
+func (s *statusStats) record(route string, status int) {
+	class := status/100 - 2
+	if class < 0 || class >= len(statusClasses) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.counts[route]
+	if !ok {
+		c = new([len(statusClasses)]uint64)
+		s.counts[route] = c
+	}
+	c[class]++
+}
+
+func (s *statusStats) snapshot() map[string]map[string]uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]map[string]uint64, len(s.counts))
+	for route, c := range s.counts {
+		classes := make(map[string]uint64, len(statusClasses))
+		for i, name := range statusClasses {
+			classes[name] = c[i]
+		}
+		out[route] = classes
+	}
+	return out
+}
+
+func (s *statusStats) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := newStatusRecorder(w)
+		next.ServeHTTP(rec, r)
+		s.record(routeName(r), rec.status)
+	})
+}
+
+// serveHTTP reports the tallies as {"<route>": {"2xx": n, ...}}.
+func (s *statusStats) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	web.WriteJSON(w, http.StatusOK, s.snapshot())
+}
+
+// routeName identifies the matched route by its path template, so
+// /books/a/page/1 and /books/b/page/2 are counted together.
+func routeName(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return "unmatched"
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestStatusStatsTallies(t *testing.T) {
+	stats := newStatusStats()
+	r := mux.NewRouter()
+	r.Use(stats.middleware)
+	r.HandleFunc("/books/{title}", func(w http.ResponseWriter, r *http.Request) {
+		code, _ := strconv.Atoi(r.URL.Query().Get("status"))
+		w.WriteHeader(code)
+	})
+	r.HandleFunc("/stats", stats.serveHTTP)
+
+	for _, code := range []int{200, 201, 200, 302, 404, 404, 404, 500} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/books/b"+strconv.Itoa(code)+"?status="+strconv.Itoa(code), nil))
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	var got map[string]map[string]uint64
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]uint64{"2xx": 3, "3xx": 1, "4xx": 3, "5xx": 1}
+	if !reflect.DeepEqual(got["/books/{title}"], want) {
+		t.Errorf("tallies for /books/{title} = %v, want %v", got["/books/{title}"], want)
+	}
+}
+
+func TestStatusStatsIgnoresInformational(t *testing.T) {
+	stats := newStatusStats()
+	stats.record("/x", 101)
+	stats.record("/x", 200)
+	if got := stats.snapshot()["/x"]; got["2xx"] != 1 || len(got) != len(statusClasses) {
+		t.Errorf("tallies = %v, want only the 200", got)
+	}
+}
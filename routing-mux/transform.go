@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+// maxTransformBody is the most transformResponses buffers. Longer
+// responses are streamed through unchanged.
+const maxTransformBody = 1 << 20
+
+// responseTransform rewrites response bodies. match picks the requests it
+// applies to, so others aren't buffered at all; apply gets the complete
+// body and the response headers, which it may change, and returns the new
+// body.
+type responseTransform struct {
+	match func(r *http.Request) bool
+	apply func(h http.Header, body []byte) []byte
+}
+
+// prettyJSON indents JSON responses to requests with ?pretty=1.
+var prettyJSON = responseTransform{
+	match: func(r *http.Request) bool { return queryParam(r, "pretty") == "1" },
+	apply: func(h http.Header, body []byte) []byte {
+		if mt, _, _ := mime.ParseMediaType(h.Get("Content-Type")); mt != "application/json" {
+			return body
+		}
+		var out bytes.Buffer
+		if err := json.Indent(&out, body, "", "  "); err != nil {
+			return body
+		}
+		return out.Bytes()
+	},
+}
+
+// transformResponses buffers the response of requests matched by any of
+// transforms and runs the matching ones over the body, in order, before it
+// is sent. A response that grows past max bytes or is flushed by the
+// handler is sent as is from then on.
+func transformResponses(max int, transforms ...responseTransform) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var apply []responseTransform
+			for _, t := range transforms {
+				if t.match(r) {
+					apply = append(apply, t)
+				}
+			}
+			if len(apply) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			tw := &transformWriter{ResponseWriter: w, max: max, status: http.StatusOK}
+			next.ServeHTTP(tw, r)
+			if tw.passThrough {
+				return
+			}
+			body := tw.buf.Bytes()
+			for _, t := range apply {
+				body = t.apply(w.Header(), body)
+			}
+			if w.Header().Get("Content-Length") != "" {
+				w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			}
+			w.WriteHeader(tw.status)
+			w.Write(body)
+		})
+	}
+}
+
+// transformWriter holds back the status and body until the handler is done,
+// unless it gives up buffering and passes everything through.
+type transformWriter struct {
+	http.ResponseWriter
+	max         int
+	buf         bytes.Buffer
+	status      int
+	wroteHeader bool
+	passThrough bool
+}
+
+func (tw *transformWriter) WriteHeader(code int) {
+	if tw.passThrough {
+		tw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if !tw.wroteHeader {
+		tw.status = code
+		tw.wroteHeader = true
+	}
+}
+
+func (tw *transformWriter) Write(b []byte) (int, error) {
+	if tw.passThrough {
+		return tw.ResponseWriter.Write(b)
+	}
+	if tw.buf.Len()+len(b) > tw.max {
+		if err := tw.stopBuffering(); err != nil {
+			return 0, err
+		}
+		return tw.ResponseWriter.Write(b)
+	}
+	return tw.buf.Write(b)
+}
+
+func (tw *transformWriter) Flush() {
+	if !tw.passThrough {
+		tw.stopBuffering()
+	}
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// stopBuffering sends what is held back and switches to pass-through.
+func (tw *transformWriter) stopBuffering() error {
+	tw.passThrough = true
+	tw.ResponseWriter.WriteHeader(tw.status)
+	_, err := tw.ResponseWriter.Write(tw.buf.Bytes())
+	tw.buf = bytes.Buffer{}
+	return err
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang/web"
+)
+
+// jsonHandler answers 201 with v as JSON.
+func jsonHandler(v interface{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		web.WriteJSON(w, http.StatusCreated, v)
+	})
+}
+
+func TestPrettyJSON(t *testing.T) {
+	h := transformResponses(maxTransformBody, prettyJSON)(jsonHandler(map[string]int{"pages": 412}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/books/dune?pretty=1", nil))
+	if want := "{\n  \"pages\": 412\n}"; strings.TrimSpace(rec.Body.String()) != want {
+		t.Errorf("?pretty=1 body = %q, want %q", rec.Body, want)
+	}
+	if rec.Code != http.StatusCreated {
+		t.Errorf("status = %d, want the handler's 201", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/books/dune", nil))
+	if body := strings.TrimSpace(rec.Body.String()); body != `{"pages":412}` {
+		t.Errorf("plain body = %q, want compact JSON", body)
+	}
+}
+
+func TestPrettyJSONLeavesOtherTypes(t *testing.T) {
+	h := transformResponses(maxTransformBody, prettyJSON)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(`{"pages":412}`))
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?pretty=1", nil))
+	if rec.Body.String() != `{"pages":412}` {
+		t.Errorf("text body = %q, want it unchanged", rec.Body)
+	}
+}
+
+func TestTransformPassesLargeResponsesThrough(t *testing.T) {
+	const limit = 64
+	big := strings.Repeat("x", 2*limit)
+	h := transformResponses(limit, prettyJSON)(jsonHandler(map[string]string{"text": big}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?pretty=1", nil))
+	if rec.Code != http.StatusCreated {
+		t.Errorf("status = %d, want 201", rec.Code)
+	}
+	if body := strings.TrimSpace(rec.Body.String()); body != `{"text":"`+big+`"}` {
+		t.Errorf("body = %q, want the compact response untouched", body)
+	}
+}
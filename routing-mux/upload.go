@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang/web"
+)
+
+var (
+	errSlowUpload      = errors.New("upload too slow")
+	errUnsupportedType = errors.New("unsupported file type")
+	errTooManyParts    = errors.New("too many multipart parts")
+)
+
+// uploadHandler stores the files of a multipart/form-data POST in dir.
+type uploadHandler struct {
+	dir string
+
+	// allowedTypes lists the media types files may have, as sniffed from
+	// their content by http.DetectContentType; the Content-Type the client
+	// sent for the part is ignored. An empty set allows any type.
+	allowedTypes map[string]bool
+
+	// maxParts caps the number of parts, file or not, and maxBytes the
+	// whole body, so a multipart bomb of many tiny parts or one endless
+	// part is cut off early. Zero disables either limit.
+	maxParts int
+	maxBytes int64
+
+	// progressEvery is how often progress of a running upload is logged;
+	// zero disables progress logging.
+	progressEvery time.Duration
+	// An upload is aborted once its throughput has stayed below minRate
+	// bytes per second for slowFor. A zero minRate disables the check.
+	minRate int64
+	slowFor time.Duration
+}
+
+type savedFile struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+func (h *uploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.maxBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, h.maxBytes)
+	}
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{h.watch(r), r.Body}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		web.WriteError(w, http.StatusBadRequest, "expected a multipart/form-data body")
+		return
+	}
+
+	var saved []savedFile
+	for parts := 1; ; parts++ {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			h.fail(w, saved, err, http.StatusBadRequest)
+			return
+		}
+		if h.maxParts > 0 && parts > h.maxParts {
+			part.Close()
+			h.fail(w, saved, fmt.Errorf("%w: more than %d", errTooManyParts, h.maxParts), http.StatusBadRequest)
+			return
+		}
+		if part.FileName() == "" {
+			part.Close()
+			continue
+		}
+		f, err := h.save(part)
+		part.Close()
+		if err != nil {
+			h.fail(w, saved, err, http.StatusInternalServerError)
+			return
+		}
+		saved = append(saved, f)
+	}
+	web.WriteJSON(w, http.StatusCreated, saved)
+}
+
+func (h *uploadHandler) save(part io.Reader) (savedFile, error) {
+	head := make([]byte, 512)
+	m, err := io.ReadFull(part, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return savedFile{}, err
+	}
+	head = head[:m]
+	if len(h.allowedTypes) > 0 {
+		ct, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+		if !h.allowedTypes[ct] {
+			return savedFile{}, fmt.Errorf("%w %s", errUnsupportedType, ct)
+		}
+	}
+
+	f, err := os.CreateTemp(h.dir, "upload-*")
+	if err != nil {
+		return savedFile{}, err
+	}
+	n, err := io.Copy(f, io.MultiReader(bytes.NewReader(head), part))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return savedFile{}, err
+	}
+	return savedFile{Name: filepath.Base(f.Name()), Size: n}, nil
+}
+
+// fail removes the files already written for a request that did not
+// complete and reports err with status, unless the upload was aborted for
+// being too slow.
+func (h *uploadHandler) fail(w http.ResponseWriter, saved []savedFile, err error, status int) {
+	for _, f := range saved {
+		os.Remove(filepath.Join(h.dir, f.Name))
+	}
+	if errors.Is(err, errSlowUpload) {
+		web.WriteError(w, http.StatusRequestTimeout, "upload aborted: throughput too low")
+		return
+	}
+	if errors.Is(err, errUnsupportedType) {
+		web.WriteError(w, http.StatusUnsupportedMediaType, err.Error())
+		return
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.Is(err, errTooManyParts) || errors.As(err, &tooLarge) {
+		web.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	log.Printf("upload: %v", err)
+	if status == http.StatusBadRequest {
+		web.WriteError(w, status, "malformed multipart body")
+		return
+	}
+	web.WriteError(w, status, "could not store upload")
+}
+
+func (h *uploadHandler) watch(r *http.Request) io.Reader {
+	if h.progressEvery <= 0 && h.minRate <= 0 {
+		return r.Body
+	}
+	now := time.Now()
+	return &progressReader{
+		r:           r.Body,
+		h:           h,
+		id:          RequestIDFromContext(r.Context()),
+		total:       r.ContentLength,
+		start:       now,
+		lastLog:     now,
+		windowStart: now,
+	}
+}
+
+// progressReader counts the bytes of a request body as the handler reads
+// them. Throughput is measured over one second windows; a client that
+// trickles data below the floor for too long gets errSlowUpload. A client
+// that stops sending entirely blocks inside Read and is cut off by the
+// server's ReadTimeout instead.
+type progressReader struct {
+	r  io.Reader
+	h  *uploadHandler
+	id string
+
+	total, n    int64
+	start       time.Time
+	lastLog     time.Time
+	windowStart time.Time
+	windowBytes int64
+	slowTime    time.Duration
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.n += int64(n)
+	p.windowBytes += int64(n)
+	now := time.Now()
+
+	if p.h.progressEvery > 0 && now.Sub(p.lastLog) >= p.h.progressEvery {
+		p.lastLog = now
+		log.Printf("request_id=%s upload: %d of %d bytes after %s", p.id, p.n, p.total, now.Sub(p.start).Round(time.Second))
+	}
+
+	if window := now.Sub(p.windowStart); p.h.minRate > 0 && window >= time.Second {
+		rate := float64(p.windowBytes) / window.Seconds()
+		if rate < float64(p.h.minRate) {
+			p.slowTime += window
+		} else {
+			p.slowTime = 0
+		}
+		p.windowStart, p.windowBytes = now, 0
+
+		if p.slowTime >= p.h.slowFor {
+			log.Printf("request_id=%s upload: aborting at %d bytes, %.0f B/s for %s", p.id, p.n, rate, p.slowTime)
+			return n, errSlowUpload
+		}
+	}
+	return n, err
+}
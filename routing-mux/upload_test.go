@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// multipartBody builds a multipart/form-data body with one file part per
+// entry of files and returns it with its Content-Type.
+func multipartBody(t *testing.T, files ...[]byte) (*bytes.Buffer, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for _, data := range files {
+		fw, err := mw.CreateFormFile("file", "upload.bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(data)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf, mw.FormDataContentType()
+}
+
+// throttledReader hands out at most chunk bytes per Read, pausing before
+// each, like a client on a very slow link.
+type throttledReader struct {
+	r     io.Reader
+	chunk int
+	pause time.Duration
+}
+
+func (t *throttledReader) Read(b []byte) (int, error) {
+	time.Sleep(t.pause)
+	if len(b) > t.chunk {
+		b = b[:t.chunk]
+	}
+	return t.r.Read(b)
+}
+
+func postUpload(h http.Handler, body io.Reader, contentType string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestUploadAbortsBelowThroughputFloor(t *testing.T) {
+	captureLog(t)
+	dir := t.TempDir()
+	h := &uploadHandler{dir: dir, minRate: 1024, slowFor: time.Second}
+	body, ct := multipartBody(t, bytes.Repeat([]byte("a"), 8<<10))
+
+	// About 320 B/s, well under the 1 KiB/s floor.
+	rec := postUpload(h, &throttledReader{r: body, chunk: 16, pause: 50 * time.Millisecond}, ct)
+
+	if rec.Code != http.StatusRequestTimeout {
+		t.Fatalf("status = %d, want 408: %s", rec.Code, rec.Body)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("aborted upload left %d files behind", len(entries))
+	}
+}
+
+func TestUploadAboveThroughputFloor(t *testing.T) {
+	dir := t.TempDir()
+	h := &uploadHandler{dir: dir, minRate: 1024, slowFor: time.Second}
+	body, ct := multipartBody(t, bytes.Repeat([]byte("a"), 8<<10))
+
+	rec := postUpload(h, body, ct)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", rec.Code, rec.Body)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("got %d stored files, want 1", len(entries))
+	}
+}
+
+// pngHeader is enough of a PNG for http.DetectContentType.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00")
+
+// elfHeader starts a Linux executable, which sniffs as
+// application/octet-stream.
+var elfHeader = []byte("\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00\x3e\x00")
+
+func TestUploadAllowsSniffedImage(t *testing.T) {
+	dir := t.TempDir()
+	h := &uploadHandler{dir: dir, allowedTypes: map[string]bool{"image/png": true}}
+	body, ct := multipartBody(t, pngHeader)
+
+	rec := postUpload(h, body, ct)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", rec.Code, rec.Body)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("got %d stored files, want 1", len(entries))
+	}
+}
+
+func TestUploadRejectsExecutable(t *testing.T) {
+	dir := t.TempDir()
+	h := &uploadHandler{dir: dir, allowedTypes: map[string]bool{"image/png": true}}
+
+	// The part claims to be a PNG; only the content counts.
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	part, _ := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Disposition": {`form-data; name="file"; filename="cat.png"`},
+		"Content-Type":        {"image/png"},
+	})
+	part.Write(elfHeader)
+	mw.Close()
+
+	rec := postUpload(h, &buf, mw.FormDataContentType())
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("status = %d, want 415: %s", rec.Code, rec.Body)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("rejected upload left %d files behind", len(entries))
+	}
+}
+
+func TestUploadRejectionRemovesEarlierFiles(t *testing.T) {
+	dir := t.TempDir()
+	h := &uploadHandler{dir: dir, allowedTypes: map[string]bool{"image/png": true}}
+	body, ct := multipartBody(t, pngHeader, elfHeader)
+
+	if rec := postUpload(h, body, ct); rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("status = %d, want 415", rec.Code)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("rejected request left %d files behind", len(entries))
+	}
+}
+
+// multipartFields builds a body of n plain form fields.
+func multipartFields(t *testing.T, n int) (*bytes.Buffer, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for i := 0; i < n; i++ {
+		if err := mw.WriteField("f", "x"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mw.Close()
+	return &buf, mw.FormDataContentType()
+}
+
+func TestUploadPartLimit(t *testing.T) {
+	h := &uploadHandler{dir: t.TempDir(), maxParts: 5}
+
+	body, ct := multipartBody(t, []byte("one"), []byte("two"))
+	if rec := postUpload(h, body, ct); rec.Code != http.StatusCreated {
+		t.Errorf("two files = %d %s, want 201", rec.Code, rec.Body)
+	}
+	body, ct = multipartFields(t, 5)
+	if rec := postUpload(h, body, ct); rec.Code != http.StatusCreated {
+		t.Errorf("five fields = %d %s, want 201", rec.Code, rec.Body)
+	}
+
+	body, ct = multipartFields(t, 6)
+	rec := postUpload(h, body, ct)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "too many multipart parts") {
+		t.Errorf("six fields = %d %s, want 400 too many parts", rec.Code, rec.Body)
+	}
+}
+
+func TestUploadTotalSizeLimit(t *testing.T) {
+	dir := t.TempDir()
+	h := &uploadHandler{dir: dir, maxBytes: 4 << 10}
+
+	body, ct := multipartBody(t, bytes.Repeat([]byte("a"), 1<<10))
+	if rec := postUpload(h, body, ct); rec.Code != http.StatusCreated {
+		t.Errorf("1 KiB file = %d %s, want 201", rec.Code, rec.Body)
+	}
+	body, ct = multipartBody(t, bytes.Repeat([]byte("a"), 3<<10), bytes.Repeat([]byte("b"), 3<<10))
+	if rec := postUpload(h, body, ct); rec.Code != http.StatusBadRequest {
+		t.Errorf("6 KiB of files = %d %s, want 400", rec.Code, rec.Body)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("got %d stored files, want only the first request's", len(entries))
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+
+	database "golang/MySQL-Database"
+	"golang/web"
+)
+
+const jsonPatchType = "application/json-patch+json"
+
+// patchOp is one operation of an RFC 6902 JSON Patch document.
+type patchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// patchError is a patch that is well-formed JSON but cannot be applied.
+// It is answered with 422.
+type patchError struct {
+	index int
+	msg   string
+}
+
+func (e *patchError) Error() string {
+	return fmt.Sprintf("operation %d: %s", e.index, e.msg)
+}
+
+// userEdit applies one validated operation to a user.
+type userEdit func(*database.User) error
+
+// patch applies a JSON Patch to the user's username, email and password.
+// Only add, replace and remove are supported, and only email may be
+// removed. Every operation is checked before any is applied, and the
+// result is saved in one transaction, so a patch takes effect completely
+// or not at all.
+func (h *userHandler) patch(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(pathVar(r, "id"))
+	if err != nil {
+		web.WriteError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != jsonPatchType {
+		web.WriteError(w, http.StatusUnsupportedMediaType, "Content-Type must be "+jsonPatchType)
+		return
+	}
+	var ops []patchOp
+	if err := web.DecodeJSON(r, &ops); err != nil {
+		web.WriteError(w, web.DecodeStatus(err), err.Error())
+		return
+	}
+
+	edits := make([]userEdit, 0, len(ops))
+	for i, op := range ops {
+		edit, err := h.userEdit(i, op)
+		if err != nil {
+			web.WriteError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		edits = append(edits, edit)
+	}
+
+	u, err := database.ModifyUser(r.Context(), h.writer(r), id, func(u *database.User) error {
+		for _, edit := range edits {
+			if err := edit(u); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	var perr *patchError
+	switch {
+	case err == nil:
+		web.WriteJSON(w, http.StatusOK, u)
+	case errors.As(err, &perr):
+		web.WriteError(w, http.StatusUnprocessableEntity, perr.Error())
+	case errors.Is(err, database.ErrUserNotFound):
+		web.WriteError(w, http.StatusNotFound, "user not found")
+	case errors.Is(err, database.ErrDuplicateUsername):
+		web.WriteError(w, http.StatusConflict, "username already taken")
+	default:
+		log.Printf("request_id=%s patch user %d: %v", RequestIDFromContext(r.Context()), id, err)
+		web.WriteError(w, http.StatusInternalServerError, "could not update user")
+	}
+}
+
+// userEdit validates op, the index'th of the patch, and returns the edit
+// it makes. Checks that depend on the stored user, such as replacing an
+// email that isn't set, happen when the edit runs.
+func (h *userHandler) userEdit(index int, op patchOp) (userEdit, error) {
+	fail := func(format string, args ...interface{}) (userEdit, error) {
+		return nil, &patchError{index: index, msg: fmt.Sprintf(format, args...)}
+	}
+
+	switch op.Op {
+	case "add", "replace", "remove":
+	case "move", "copy", "test":
+		return fail("op %q is not supported", op.Op)
+	default:
+		return fail("unknown op %q", op.Op)
+	}
+
+	var value string
+	if op.Op != "remove" {
+		if op.Value == nil {
+			return fail("%s needs a value", op.Op)
+		}
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return fail("value for %s must be a string", op.Path)
+		}
+	}
+
+	switch op.Path {
+	case "/username":
+		if op.Op == "remove" {
+			return fail("username cannot be removed")
+		}
+		if database.NormalizeUsername(value) == "" {
+			return fail("username must not be empty")
+		}
+		return func(u *database.User) error {
+			u.Username = value
+			return nil
+		}, nil
+
+	case "/email":
+		return func(u *database.User) error {
+			if op.Op != "add" && u.Email == "" {
+				return &patchError{index: index, msg: "path /email does not exist"}
+			}
+			u.Email = value
+			return nil
+		}, nil
+
+	case "/password":
+		if op.Op == "remove" {
+			return fail("password cannot be removed")
+		}
+		if report := h.passwords.evaluate(value); !report.OK {
+			return fail("password does not meet the policy")
+		}
+		return func(u *database.User) error {
+			u.Password = value
+			return nil
+		}, nil
+	}
+	return fail("path %q is not supported", op.Path)
+}
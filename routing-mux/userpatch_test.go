@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+)
+
+// patchUser sends a JSON Patch for user 7 to a router serving PATCH
+// /users/{id}.
+func patchUser(t *testing.T, h *userHandler, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := mux.NewRouter()
+	r.HandleFunc("/users/{id:[0-9]+}", h.patch).Methods(http.MethodPatch)
+	req := httptest.NewRequest(http.MethodPatch, "/users/7", strings.NewReader(body))
+	req.Header.Set("Content-Type", jsonPatchType)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+// expectModify expects ModifyUser to lock user 7, stored with email, and
+// save it as username and newEmail.
+func expectModify(mock sqlmock.Sqlmock, email, username, newEmail string) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM users WHERE id = \? AND deleted_at IS NULL FOR UPDATE`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(7, "alice", "hash", email, time.Now()))
+	mock.ExpectExec(`UPDATE users SET username = \?, email = \?, password = \?`).
+		WithArgs(username, newEmail, "hash", 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func TestPatchUserReplace(t *testing.T) {
+	db, mock := newPingMock(t)
+	expectModify(mock, "alice@example.com", "alicia", "alice@example.com")
+
+	rec := patchUser(t, &userHandler{db: db}, `[{"op":"replace","path":"/username","value":"alicia"}]`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PATCH = %d %s, want 200", rec.Code, rec.Body)
+	}
+	var got struct{ Username string }
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || got.Username != "alicia" {
+		t.Errorf("body = %s, want the renamed user", rec.Body)
+	}
+}
+
+func TestPatchUserRemoveEmail(t *testing.T) {
+	db, mock := newPingMock(t)
+	expectModify(mock, "alice@example.com", "alice", "")
+
+	if rec := patchUser(t, &userHandler{db: db}, `[{"op":"remove","path":"/email"}]`); rec.Code != http.StatusOK {
+		t.Fatalf("PATCH = %d %s, want 200", rec.Code, rec.Body)
+	}
+}
+
+func TestPatchUserRejectsInvalidOps(t *testing.T) {
+	tests := []struct {
+		name, body string
+	}{
+		{"unknown path", `[{"op":"replace","path":"/id","value":"1"}]`},
+		{"removing username", `[{"op":"remove","path":"/username"}]`},
+		{"unsupported op", `[{"op":"move","from":"/email","path":"/username"}]`},
+		// The first op would be valid on its own; nothing may be applied.
+		{"valid then invalid", `[{"op":"replace","path":"/username","value":"alicia"},{"op":"add","path":"/admin","value":"yes"}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := newPingMock(t)
+			rec := patchUser(t, &userHandler{db: db}, tt.body)
+			if rec.Code != http.StatusUnprocessableEntity {
+				t.Errorf("PATCH = %d %s, want 422", rec.Code, rec.Body)
+			}
+		})
+	}
+}
+
+func TestPatchUserRemoveMissingEmailRollsBack(t *testing.T) {
+	db, mock := newPingMock(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FOR UPDATE`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(7, "alice", "hash", "", time.Now()))
+	mock.ExpectRollback()
+
+	if rec := patchUser(t, &userHandler{db: db}, `[{"op":"remove","path":"/email"}]`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("PATCH = %d %s, want 422", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	database "golang/MySQL-Database"
+	"golang/web"
+)
+
+type userHandler struct {
+	db        *sql.DB
+	passwords passwordPolicy
+
+	// failover, when set, picks the database for writes.
+	failover *failover
+
+	// router builds the Location of created users from the "user" route.
+	router *mux.Router
+
+	// avatarDefault is Gravatar's d= fallback for addresses without an
+	// image, avatarSize the s= pixel size used unless the request asks for
+	// another one.
+	avatarDefault string
+	avatarSize    int
+
+	// metadataKeys are the metadata fields list may filter on.
+	metadataKeys map[string]bool
+}
+
+// dbConn is what both the pool and a reserved *sql.Conn offer.
+type dbConn interface {
+	database.Querier
+	database.TxBeginner
+}
+
+// querier returns the connection reserved for r by database.Backpressure,
+// falling back to the pool for routes mounted without it. main reserves
+// it from the database failover picks, so while the secondary is promoted
+// it takes reads as well as writes.
+func (h *userHandler) querier(r *http.Request) dbConn {
+	if conn := database.ConnFromContext(r.Context()); conn != nil {
+		return conn
+	}
+	return h.db
+}
+
+// writer is querier for writes. Without a reserved connection, writes go
+// to whichever database failover currently picks.
+func (h *userHandler) writer(r *http.Request) dbConn {
+	if conn := database.ConnFromContext(r.Context()); conn != nil {
+		return conn
+	}
+	if h.failover != nil {
+		return h.failover.writer()
+	}
+	return h.db
+}
+
+type createUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Email    string `json:"email"`
+}
+
+func (h *userHandler) create(w http.ResponseWriter, r *http.Request) {
+	var req createUserRequest
+	if err := web.DecodeJSON(r, &req); err != nil {
+		web.WriteError(w, web.DecodeStatus(err), err.Error())
+		return
+	}
+	if req.Username == "" || req.Password == "" {
+		web.WriteError(w, http.StatusBadRequest, "username and password are required")
+		return
+	}
+
+	if report := h.passwords.evaluate(req.Password); !report.OK {
+		web.WriteJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"error":  "password does not meet the policy",
+			"failed": report.Failed,
+		})
+		return
+	}
+
+	u := database.User{Username: req.Username, Password: req.Password, Email: req.Email}
+	if _, err := database.CreateUser(r.Context(), h.writer(r), &u); err != nil {
+		if errors.Is(err, database.ErrDuplicateUsername) {
+			web.WriteError(w, http.StatusConflict, "username already taken")
+			return
+		}
+		log.Printf("create user: %v", err)
+		web.WriteError(w, http.StatusInternalServerError, "could not create user")
+		return
+	}
+	if loc, err := h.router.Get("user").URL("id", strconv.Itoa(u.ID)); err == nil {
+		w.Header().Set("Location", loc.String())
+	} else {
+		log.Printf("create user: build location: %v", err)
+	}
+	web.WriteJSON(w, http.StatusCreated, u)
+}
+
+// get returns a single user.
+func (h *userHandler) get(w http.ResponseWriter, r *http.Request) {
+	if u, ok := h.lookup(w, r); ok {
+		web.WriteJSON(w, http.StatusOK, u)
+	}
+}
+
+// availability reports whether the username query parameter is still free,
+// for signup forms to check before submitting.
+func (h *userHandler) availability(w http.ResponseWriter, r *http.Request) {
+	username := database.NormalizeUsername(queryParam(r, "username"))
+	if username == "" {
+		web.WriteError(w, http.StatusBadRequest, "username is required")
+		return
+	}
+	taken, err := database.UsernameExists(r.Context(), h.querier(r), username)
+	if err != nil {
+		log.Printf("username availability: %v", err)
+		web.WriteError(w, http.StatusInternalServerError, "could not check username")
+		return
+	}
+	web.WriteJSON(w, http.StatusOK, map[string]interface{}{"username": username, "available": !taken})
+}
+
+// list returns a page of users. Navigation is in the Link header.
+// ?meta.<key>=<value> keeps only the users whose metadata field key equals
+// value; key must be one of h.metadataKeys and only one filter is allowed.
+func (h *userHandler) list(w http.ResponseWriter, r *http.Request) {
+	key, value, err := h.metadataFilter(r)
+	if err != nil {
+		web.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, perPage := pageParams(r)
+	var users []database.User
+	var hasMore bool
+	if key == "" {
+		users, hasMore, err = database.ListUsers(r.Context(), h.querier(r), perPage, (page-1)*perPage)
+	} else {
+		users, hasMore, err = database.ListUsersByMetadata(r.Context(), h.querier(r), key, value, perPage, (page-1)*perPage)
+	}
+	if err != nil {
+		log.Printf("list users: %v", err)
+		web.WriteError(w, http.StatusInternalServerError, "could not list users")
+		return
+	}
+	setPageLinks(w, r, page, perPage, hasMore)
+	web.WriteJSON(w, http.StatusOK, users)
+}
+
+// metadataFilter returns the key and value of the request's meta.<key>
+// parameter, or an empty key if there is none.
+func (h *userHandler) metadataFilter(r *http.Request) (key, value string, err error) {
+	for name, values := range r.URL.Query() {
+		if !strings.HasPrefix(name, "meta.") {
+			continue
+		}
+		k := strings.TrimPrefix(name, "meta.")
+		if key != "" || len(values) > 1 {
+			return "", "", errors.New("only one meta filter is allowed")
+		}
+		if !h.metadataKeys[k] {
+			return "", "", fmt.Errorf("cannot filter on metadata key %q", k)
+		}
+		key, value = k, values[0]
+	}
+	return key, value, nil
+}
+
+// me returns the user of the current session.
+func (h *userHandler) me(w http.ResponseWriter, r *http.Request) {
+	id, _ := sessionUserID(r.Context())
+	users, err := database.UsersByID(r.Context(), h.querier(r), []int{id})
+	if err != nil {
+		log.Printf("load user %d: %v", id, err)
+		web.WriteError(w, http.StatusInternalServerError, "could not load user")
+		return
+	}
+	u, ok := users[id]
+	if !ok {
+		web.WriteError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	web.WriteJSON(w, http.StatusOK, u)
+}
+
+// lookup loads the user named by the {id} path variable. When it reports
+// false the error response has already been written.
+func (h *userHandler) lookup(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	id, err := strconv.Atoi(pathVar(r, "id"))
+	if err != nil {
+		web.WriteError(w, http.StatusNotFound, "user not found")
+		return database.User{}, false
+	}
+	users, err := database.UsersByID(r.Context(), h.querier(r), []int{id})
+	if err != nil {
+		log.Printf("load user %d: %v", id, err)
+		web.WriteError(w, http.StatusInternalServerError, "could not load user")
+		return database.User{}, false
+	}
+	u, ok := users[id]
+	if !ok {
+		web.WriteError(w, http.StatusNotFound, "user not found")
+		return database.User{}, false
+	}
+	return u, true
+}
+
+// topCommenters returns a page of users ranked by comment count. ?n= is
+// accepted as a synonym for ?per_page=.
+func (h *userHandler) topCommenters(w http.ResponseWriter, r *http.Request) {
+	page, perPage := pageParams(r)
+	if n := web.QueryInt(r, "n", 0); n > 0 && n <= database.MaxPageSize {
+		perPage = n
+	}
+	users, hasMore, err := database.TopCommenters(r.Context(), h.querier(r), perPage, (page-1)*perPage)
+	if err != nil {
+		log.Printf("top commenters: %v", err)
+		web.WriteError(w, http.StatusInternalServerError, "could not rank users")
+		return
+	}
+	setPageLinks(w, r, page, perPage, hasMore)
+	web.WriteJSON(w, http.StatusOK, users)
+}
+
+// avatar redirects to the Gravatar image for the user's email address.
+func (h *userHandler) avatar(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.lookup(w, r)
+	if !ok {
+		return
+	}
+
+	size := h.avatarSize
+	if s, err := strconv.Atoi(queryParam(r, "s")); err == nil && s > 0 && s <= 2048 {
+		size = s
+	}
+	http.Redirect(w, r, gravatarURL(u.Email, h.avatarDefault, size), http.StatusFound)
+}
+
+// gravatarURL follows https://docs.gravatar.com/api/avatars/images/: the
+// hash is the MD5 of the trimmed, lower-cased address.
+func gravatarURL(email, fallback string, size int) string {
+	sum := md5.Sum([]byte(strings.ToLower(strings.TrimSpace(email))))
+	q := url.Values{}
+	if fallback != "" {
+		q.Set("d", fallback)
+	}
+	q.Set("s", strconv.Itoa(size))
+	return "https://www.gravatar.com/avatar/" + hex.EncodeToString(sum[:]) + "?" + q.Encode()
+}
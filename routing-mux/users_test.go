@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+
+	"golang/web"
+)
+
+// userColumns are the columns the users queries scan.
+var userColumns = []string{"id", "username", "password", "email", "created_at"}
+
+func TestAvatarRedirectsToGravatar(t *testing.T) {
+	db, mock := newPingMock(t)
+	mock.ExpectQuery("FROM users WHERE id IN").WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(5, "alice", "hash", " Alice@Example.com ", time.Now()))
+
+	r := mux.NewRouter()
+	users := &userHandler{db: db, router: r, avatarDefault: "identicon", avatarSize: 80}
+	r.HandleFunc("/users/{id:[0-9]+}/avatar", users.avatar)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/5/avatar?s=200", nil))
+
+	if rec.Code != http.StatusFound {
+		t.Fatalf("status = %d, want 302", rec.Code)
+	}
+	loc, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := md5.Sum([]byte("alice@example.com"))
+	if want := "/avatar/" + hex.EncodeToString(sum[:]); loc.Host != "www.gravatar.com" || loc.Path != want {
+		t.Errorf("Location = %s, want https://www.gravatar.com%s", loc, want)
+	}
+	if q := loc.Query(); q.Get("s") != "200" || q.Get("d") != "identicon" {
+		t.Errorf("Location query = %v, want s=200 and d=identicon", q)
+	}
+}
+
+func TestAvatarUnknownUser(t *testing.T) {
+	db, mock := newPingMock(t)
+	mock.ExpectQuery("FROM users WHERE id IN").WithArgs(9).WillReturnRows(sqlmock.NewRows(userColumns))
+
+	r := mux.NewRouter()
+	users := &userHandler{db: db, router: r, avatarSize: 80}
+	r.HandleFunc("/users/{id:[0-9]+}/avatar", users.avatar)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/9/avatar", nil))
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "user not found") {
+		t.Fatalf("got %d %s, want 404 user not found", rec.Code, rec.Body)
+	}
+}
+
+func TestListUsersMetadataFilter(t *testing.T) {
+	db, mock := newPingMock(t)
+	now := time.Now()
+	mock.ExpectQuery(`JSON_EXTRACT\(metadata, '\$\.plan'\)\) = \? ORDER BY id LIMIT`).WithArgs("pro", 3, 0).
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(1, "alice", "h", "", now).
+			AddRow(3, "carol", "h", "", now))
+
+	users := &userHandler{db: db, metadataKeys: map[string]bool{"plan": true}}
+	rec := httptest.NewRecorder()
+	users.list(rec, httptest.NewRequest(http.MethodGet, "/users?meta.plan=pro&per_page=2", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `"alice"`) || !strings.Contains(body, `"carol"`) {
+		t.Errorf("body = %s, want alice and carol", body)
+	}
+	if link := rec.Header().Get("Link"); strings.Contains(link, `rel="next"`) {
+		t.Errorf("Link = %q, want no next page", link)
+	}
+}
+
+func TestListUsersMetadataFilterRejected(t *testing.T) {
+	tests := []struct {
+		name, query string
+	}{
+		{"key not allowed", "meta.password_hint=x"},
+		{"injection", "meta." + url.QueryEscape("plan') OR 1=1 -- ") + "=x"},
+		{"two filters", "meta.plan=pro&meta.team=core"},
+		{"repeated filter", "meta.plan=pro&meta.plan=free"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := newPingMock(t)
+			users := &userHandler{db: db, metadataKeys: map[string]bool{"plan": true, "team": true}}
+			rec := httptest.NewRecorder()
+			users.list(rec, httptest.NewRequest(http.MethodGet, "/users?"+tt.query, nil))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d %s, want 400 without a query", rec.Code, rec.Body)
+			}
+		})
+	}
+}
+
+func TestCreateUserRejectsBadBodies(t *testing.T) {
+	db, _ := newPingMock(t)
+	users := &userHandler{db: db, passwords: passwordPolicy{MinLength: 1}}
+	tests := map[string]struct {
+		body string
+		want int
+	}{
+		"malformed": {`{"username":`, http.StatusBadRequest},
+		"oversized": {`{"username":"carol","password":"` + strings.Repeat("a", web.MaxJSONBody) + `"}`, http.StatusRequestEntityTooLarge},
+	}
+	for name, tt := range tests {
+		rec := httptest.NewRecorder()
+		users.create(rec, httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("%s body: POST /users = %d, want %d", name, rec.Code, tt.want)
+		}
+	}
+}
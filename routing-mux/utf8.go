@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"unicode/utf8"
+
+	"golang/web"
+)
+
+const maxUTF8CheckBody = 1 << 20
+
+// requireUTF8 rejects requests whose path, query or body contain invalid
+// UTF-8 with 400. MySQL truncates a utf8mb4 string at the first bad byte,
+// and encoding/json silently replaces such bytes with U+FFFD, so the check
+// has to happen on the raw request before it is decoded.
+func requireUTF8(next http.Handler) http.Handler {
+	return utf8Guard(next, false)
+}
+
+// sanitizeUTF8 replaces invalid UTF-8 in the request body with U+FFFD
+// instead of rejecting it. Path and query are still validated.
+func sanitizeUTF8(next http.Handler) http.Handler {
+	return utf8Guard(next, true)
+}
+
+// utf8Body is sanitizeUTF8 when sanitize is set and requireUTF8 otherwise.
+func utf8Body(sanitize bool) func(http.Handler) http.Handler {
+	if sanitize {
+		return sanitizeUTF8
+	}
+	return requireUTF8
+}
+
+func utf8Guard(next http.Handler, sanitize bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !utf8.ValidString(r.URL.Path) || !validQuery(r) {
+			web.WriteError(w, http.StatusBadRequest, "request parameters must be valid UTF-8")
+			return
+		}
+
+		if r.Body != nil && r.Body != http.NoBody {
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxUTF8CheckBody))
+			if err != nil {
+				web.WriteError(w, http.StatusBadRequest, "could not read request body")
+				return
+			}
+			if !utf8.Valid(body) {
+				if !sanitize {
+					web.WriteError(w, http.StatusBadRequest, "request body must be valid UTF-8")
+					return
+				}
+				body = bytes.ToValidUTF8(body, []byte("\uFFFD"))
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			r.ContentLength = int64(len(body))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func validQuery(r *http.Request) bool {
+	for key, values := range r.URL.Query() {
+		if !utf8.ValidString(key) {
+			return false
+		}
+		for _, v := range values {
+			if !utf8.ValidString(v) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang/web"
+)
+
+// decodeUsername stands in for the create-user handler: it decodes the
+// JSON body and echoes the username.
+var decodeUsername = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username string `json:"username"`
+	}
+	if err := web.DecodeJSON(r, &req); err != nil {
+		web.WriteError(w, web.DecodeStatus(err), err.Error())
+		return
+	}
+	w.Write([]byte(req.Username))
+})
+
+func TestRequireUTF8RejectsInvalidBody(t *testing.T) {
+	body := "{\"username\":\"al\xffice\"}"
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	utf8Body(false)(decodeUsername).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "valid UTF-8") {
+		t.Errorf("body = %q, want the UTF-8 error", rec.Body.String())
+	}
+}
+
+func TestSanitizeUTF8ReplacesInvalidBytes(t *testing.T) {
+	body := "{\"username\":\"al\xffice\"}"
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	utf8Body(true)(decodeUsername).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if got := rec.Body.String(); got != "al�ice" {
+		t.Errorf("username = %q, want the bad byte replaced", got)
+	}
+}
+
+func TestUTF8InvalidQueryRejectedEvenWhenSanitizing(t *testing.T) {
+	for _, sanitize := range []bool{false, true} {
+		req := httptest.NewRequest(http.MethodPost, "/users?name=%ff", strings.NewReader(`{}`))
+		rec := httptest.NewRecorder()
+		utf8Body(sanitize)(decodeUsername).ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("sanitize=%v: status = %d, want 400", sanitize, rec.Code)
+		}
+	}
+}
+
+func TestRequireUTF8PassesValidBody(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"username":"zoë"}`))
+	rec := httptest.NewRecorder()
+	utf8Body(false)(decodeUsername).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "zoë" {
+		t.Fatalf("got %d %q, want 200 zoë", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+type varyKey struct{}
+
+// varySet collects the request headers a response depends on.
+type varySet struct {
+	mu      sync.Mutex
+	headers []string
+}
+
+func (s *varySet) add(headers ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.headers = append(s.headers, headers...)
+}
+
+// varyOn records that the response to r depends on the given request
+// headers, so caches keep separate copies per value. It requires the vary
+// middleware and must be called before the response is written.
+func varyOn(r *http.Request, headers ...string) {
+	if s, ok := r.Context().Value(varyKey{}).(*varySet); ok {
+		s.add(headers...)
+	}
+}
+
+// vary emits a single Vary header combining everything handlers declared
+// through varyOn with any Vary values they set directly.
+func vary(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		set := &varySet{}
+		vw := &varyWriter{ResponseWriter: w, set: set}
+		next.ServeHTTP(vw, r.WithContext(context.WithValue(r.Context(), varyKey{}, set)))
+		vw.setVary()
+	})
+}
+
+type varyWriter struct {
+	http.ResponseWriter
+	set  *varySet
+	done bool
+}
+
+func (vw *varyWriter) WriteHeader(code int) {
+	vw.setVary()
+	vw.ResponseWriter.WriteHeader(code)
+}
+
+func (vw *varyWriter) Write(b []byte) (int, error) {
+	vw.setVary()
+	return vw.ResponseWriter.Write(b)
+}
+
+func (vw *varyWriter) Flush() {
+	vw.setVary()
+	if f, ok := vw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (vw *varyWriter) setVary() {
+	if vw.done {
+		return
+	}
+	vw.done = true
+
+	h := vw.Header()
+	var names []string
+	for _, v := range h.Values("Vary") {
+		names = append(names, strings.Split(v, ",")...)
+	}
+	vw.set.mu.Lock()
+	names = append(names, vw.set.headers...)
+	vw.set.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var out []string
+	for _, name := range names {
+		name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		out = append(out, name)
+	}
+	if len(out) > 0 {
+		h.Set("Vary", strings.Join(out, ", "))
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVaryCombinesDeclaredHeaders(t *testing.T) {
+	h := vary(GzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		varyOn(r, "accept", "Authorization")
+		w.Header().Set("Vary", "Origin, Accept")
+		w.Write([]byte("ok"))
+	})))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	want := "Origin, Accept, Accept-Encoding, Authorization"
+	if got := rec.Header().Values("Vary"); len(got) != 1 || got[0] != want {
+		t.Errorf("Vary = %q, want one header %q", got, want)
+	}
+}
+
+func TestVaryWithoutDeclarations(t *testing.T) {
+	h := vary(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := rec.Header().Get("Vary"); got != "" {
+		t.Errorf("Vary = %q, want none", got)
+	}
+}
+
+func TestVaryOnWithoutMiddleware(t *testing.T) {
+	// Outside vary, varyOn has nowhere to record and must not panic.
+	varyOn(httptest.NewRequest(http.MethodGet, "/", nil), "Accept")
+}
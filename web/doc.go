@@ -0,0 +1,2 @@
+// Package web holds the HTTP helpers shared by the example servers.
+package web
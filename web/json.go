@@ -0,0 +1,90 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// MaxJSONBody is the largest request body DecodeJSON will read.
+const MaxJSONBody = 1 << 20
+
+// ErrBodyTooLarge is returned by DecodeJSON when the body is longer than
+// MaxJSONBody.
+var ErrBodyTooLarge = errors.New("request body too large")
+
+// ErrShortBody is reported by body readers that saw the request end before
+// its declared Content-Length, which usually means a truncated upload.
+var ErrShortBody = errors.New("request body shorter than Content-Length")
+
+// WriteJSON encodes v as the response body with the given status.
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("write json: %v", err)
+	}
+}
+
+// WriteError sends {"error": msg} with the given status.
+func WriteError(w http.ResponseWriter, status int, msg string) {
+	WriteJSON(w, status, map[string]string{"error": msg})
+}
+
+// DecodeJSON decodes the request body, which must hold exactly one JSON
+// value, into v. Fields that v doesn't have are an error rather than being
+// dropped silently, and bodies over MaxJSONBody fail with ErrBodyTooLarge.
+func DecodeJSON(r *http.Request, v interface{}) error {
+	body := &limitedReader{r: r.Body, n: MaxJSONBody}
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		if errors.Is(err, ErrBodyTooLarge) {
+			return err
+		}
+		return fmt.Errorf("invalid JSON body: %w", err)
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		if errors.Is(err, ErrBodyTooLarge) {
+			return err
+		}
+		if errors.Is(err, ErrShortBody) {
+			return fmt.Errorf("invalid JSON body: %w", err)
+		}
+		return errors.New("invalid JSON body: more than one value")
+	}
+	return nil
+}
+
+// DecodeStatus is the response status for an error from DecodeJSON.
+func DecodeStatus(err error) int {
+	if errors.Is(err, ErrBodyTooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// limitedReader is io.LimitReader with an error instead of a silent EOF
+// once n bytes have been read, so a truncated body can't decode as valid.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, ErrBodyTooLarge
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, ErrBodyTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,77 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type decodeTarget struct {
+	Author string `json:"author"`
+	Pages  int    `json:"pages"`
+}
+
+func decode(body string) (decodeTarget, error) {
+	var v decodeTarget
+	err := DecodeJSON(httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)), &v)
+	return v, err
+}
+
+func TestDecodeJSON(t *testing.T) {
+	v, err := decode(`{"author":"Herbert","pages":412}`)
+	if err != nil || v != (decodeTarget{"Herbert", 412}) {
+		t.Fatalf("DecodeJSON = %+v, %v", v, err)
+	}
+}
+
+func TestDecodeJSONMalformed(t *testing.T) {
+	for _, body := range []string{
+		``,
+		`{"author":`,
+		`{"author":"Herbert"`,
+		`{"pages":"many"}`,
+		`{"author":"Herbert","isbn":"0441013597"}`,
+		`{"author":"a"} {"author":"b"}`,
+	} {
+		_, err := decode(body)
+		if err == nil {
+			t.Errorf("DecodeJSON(%q) succeeded", body)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), "invalid JSON body") || DecodeStatus(err) != http.StatusBadRequest {
+			t.Errorf("DecodeJSON(%q) = %v (status %d), want an invalid JSON body 400", body, err, DecodeStatus(err))
+		}
+	}
+}
+
+func TestDecodeJSONOversized(t *testing.T) {
+	for name, body := range map[string]string{
+		"one long value":        `{"author":"` + strings.Repeat("a", MaxJSONBody) + `"}`,
+		"valid then trailing":   `{"author":"a"}` + strings.Repeat(" ", MaxJSONBody),
+		"exactly one byte over": `{"author":"` + strings.Repeat("a", MaxJSONBody-len(`{"author":""}`)+1) + `"}`,
+	} {
+		_, err := decode(body)
+		if !errors.Is(err, ErrBodyTooLarge) || DecodeStatus(err) != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s: DecodeJSON error = %v (status %d), want ErrBodyTooLarge 413", name, err, DecodeStatus(err))
+		}
+	}
+	if _, err := decode(`{"author":"` + strings.Repeat("a", MaxJSONBody-len(`{"author":""}`)) + `"}`); err != nil {
+		t.Errorf("body of exactly MaxJSONBody bytes: %v", err)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	rec := httptest.NewRecorder()
+	WriteJSON(rec, http.StatusCreated, decodeTarget{"Herbert", 412})
+
+	if rec.Code != http.StatusCreated || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("got %d %q, want 201 application/json", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var v decodeTarget
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil || v != (decodeTarget{"Herbert", 412}) {
+		t.Errorf("body = %s, %v", rec.Body, err)
+	}
+}
@@ -0,0 +1,80 @@
+package web
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Respond writes v as JSON or as plain text, whichever the request's
+// Accept header prefers. JSON wins ties and is used for an absent Accept or
+// */*; text is the fallback when JSON is not acceptable. The text form of v
+// is fmt's, so types get a readable one by implementing fmt.Stringer.
+func Respond(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	w.Header().Add("Vary", "Accept")
+	if prefersJSON(r.Header.Get("Accept")) {
+		WriteJSON(w, status, v)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	fmt.Fprintln(w, v)
+}
+
+// RespondError is WriteError negotiated like Respond; the text form is msg
+// alone.
+func RespondError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	if prefersJSON(r.Header.Get("Accept")) {
+		w.Header().Add("Vary", "Accept")
+		WriteError(w, status, msg)
+		return
+	}
+	Respond(w, r, status, msg)
+}
+
+// prefersJSON compares the q the Accept header gives application/json with
+// the one it gives text/plain, each taken from the most specific matching
+// range.
+func prefersJSON(accept string) bool {
+	if strings.TrimSpace(accept) == "" {
+		return true
+	}
+	jsonQ := acceptQ(accept, "application", "json")
+	textQ := acceptQ(accept, "text", "plain")
+	return jsonQ > 0 && jsonQ >= textQ
+}
+
+func acceptQ(accept, typ, sub string) float64 {
+	q, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		t, s, _ := strings.Cut(mt, "/")
+		var spec int
+		switch {
+		case t == typ && s == sub:
+			spec = 2
+		case t == typ && s == "*":
+			spec = 1
+		case t == "*" && s == "*":
+			spec = 0
+		default:
+			continue
+		}
+		if spec < specificity {
+			continue
+		}
+		specificity = spec
+		q = 1
+		if v, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+	}
+	return q
+}
@@ -0,0 +1,66 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type greeting struct {
+	Name string `json:"name"`
+}
+
+func (g greeting) String() string { return "hello " + g.Name }
+
+func TestRespond(t *testing.T) {
+	tests := []struct {
+		accept   string
+		wantType string
+		wantBody string
+	}{
+		{"", "application/json", `{"name":"ada"}`},
+		{"*/*", "application/json", `{"name":"ada"}`},
+		{"application/json", "application/json", `{"name":"ada"}`},
+		{"text/plain", "text/plain; charset=utf-8", "hello ada"},
+		{"text/*", "text/plain; charset=utf-8", "hello ada"},
+		{"application/json;q=0.5, text/plain", "text/plain; charset=utf-8", "hello ada"},
+		{"text/plain, application/json", "application/json", `{"name":"ada"}`},
+		{"text/html", "text/plain; charset=utf-8", "hello ada"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		rec := httptest.NewRecorder()
+		Respond(rec, req, http.StatusCreated, greeting{"ada"})
+
+		if rec.Code != http.StatusCreated {
+			t.Errorf("Accept %q: status = %d, want 201", tt.accept, rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != tt.wantType {
+			t.Errorf("Accept %q: Content-Type = %q, want %q", tt.accept, ct, tt.wantType)
+		}
+		if body := strings.TrimSpace(rec.Body.String()); body != tt.wantBody {
+			t.Errorf("Accept %q: body = %q, want %q", tt.accept, body, tt.wantBody)
+		}
+		if v := rec.Header().Get("Vary"); v != "Accept" {
+			t.Errorf("Accept %q: Vary = %q, want Accept", tt.accept, v)
+		}
+	}
+}
+
+func TestRespondError(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "text/plain")
+	rec := httptest.NewRecorder()
+	RespondError(rec, req, http.StatusNotFound, "book not found")
+
+	if rec.Code != http.StatusNotFound || strings.TrimSpace(rec.Body.String()) != "book not found" {
+		t.Errorf("got %d %q, want 404 with the bare message", rec.Code, rec.Body)
+	}
+	if vary := rec.Header().Values("Vary"); len(vary) != 1 {
+		t.Errorf("Vary = %q, want Accept once", vary)
+	}
+}
@@ -0,0 +1,41 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// ErrMissingQuery is wrapped by RequireQuery's error for an absent or
+// empty parameter. It is the client's mistake, so a 400.
+var ErrMissingQuery = errors.New("missing query parameter")
+
+// QueryString returns the key query parameter, or def when it is absent
+// or empty.
+func QueryString(r *http.Request, key, def string) string {
+	if v := r.URL.Query().Get(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// QueryInt returns the key query parameter as an int, or def when it is
+// absent or not a valid integer.
+func QueryInt(r *http.Request, key string, def int) int {
+	n, err := strconv.Atoi(r.URL.Query().Get(key))
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// RequireQuery returns the key query parameter, or an error wrapping
+// ErrMissingQuery when it is absent or empty.
+func RequireQuery(r *http.Request, key string) (string, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return "", fmt.Errorf("%w %q", ErrMissingQuery, key)
+	}
+	return v, nil
+}
@@ -0,0 +1,55 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func queryRequest(rawQuery string) *http.Request {
+	return httptest.NewRequest(http.MethodGet, "/search?"+rawQuery, nil)
+}
+
+func TestQueryInt(t *testing.T) {
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"limit=25", 25},
+		{"limit=-3", -3},
+		{"", 10},
+		{"limit=", 10},
+		{"limit=abc", 10},
+		{"limit=2.5", 10},
+		{"limit=99999999999999999999", 10},
+		{"limit=7&limit=8", 7},
+	}
+	for _, tt := range tests {
+		if got := QueryInt(queryRequest(tt.query), "limit", 10); got != tt.want {
+			t.Errorf("QueryInt(%q) = %d, want %d", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestQueryString(t *testing.T) {
+	if got := QueryString(queryRequest("sort=name"), "sort", "id"); got != "name" {
+		t.Errorf("present: %q, want name", got)
+	}
+	for _, q := range []string{"", "sort="} {
+		if got := QueryString(queryRequest(q), "sort", "id"); got != "id" {
+			t.Errorf("QueryString(%q) = %q, want the default", q, got)
+		}
+	}
+}
+
+func TestRequireQuery(t *testing.T) {
+	if v, err := RequireQuery(queryRequest("q=css"), "q"); v != "css" || err != nil {
+		t.Errorf("present: %q, %v", v, err)
+	}
+	for _, q := range []string{"", "q=", "other=css"} {
+		if _, err := RequireQuery(queryRequest(q), "q"); !errors.Is(err, ErrMissingQuery) {
+			t.Errorf("RequireQuery(%q) error = %v, want ErrMissingQuery", q, err)
+		}
+	}
+}
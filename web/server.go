@@ -0,0 +1,91 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ErrAddrInUse is returned by ListenAndServe when another process already
+// listens on the server's address.
+var ErrAddrInUse = errors.New("address already in use")
+
+// Timeouts bound how long a single connection may take. The zero
+// http.Server has none, so a client that sends its request or reads the
+// response slowly enough (slowloris) can hold a connection open forever.
+type Timeouts struct {
+	// ReadHeader limits reading the request line and headers, which is
+	// what slowloris clients drag out.
+	ReadHeader time.Duration
+	// Read limits reading the whole request, headers and body, so it also
+	// bounds how long an upload may take.
+	Read time.Duration
+	// Write limits the time from the end of the request headers to the end
+	// of the response.
+	Write time.Duration
+	// Idle limits how long a keep-alive connection waits for the next
+	// request.
+	Idle time.Duration
+}
+
+// DefaultTimeouts suit small JSON and HTML requests and responses. Servers
+// taking uploads or sending large downloads need longer Read and Write.
+var DefaultTimeouts = Timeouts{
+	ReadHeader: 5 * time.Second,
+	Read:       5 * time.Second,
+	Write:      10 * time.Second,
+	Idle:       120 * time.Second,
+}
+
+// NewServer returns an http.Server for addr and h with t applied. Zero
+// fields of t take the value from DefaultTimeouts.
+func NewServer(addr string, h http.Handler, t Timeouts) *http.Server {
+	if t.ReadHeader == 0 {
+		t.ReadHeader = DefaultTimeouts.ReadHeader
+	}
+	if t.Read == 0 {
+		t.Read = DefaultTimeouts.Read
+	}
+	if t.Write == 0 {
+		t.Write = DefaultTimeouts.Write
+	}
+	if t.Idle == 0 {
+		t.Idle = DefaultTimeouts.Idle
+	}
+	return &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		ReadHeaderTimeout: t.ReadHeader,
+		ReadTimeout:       t.Read,
+		WriteTimeout:      t.Write,
+		IdleTimeout:       t.Idle,
+	}
+}
+
+// ListenAndServe is srv.ListenAndServe, or ListenAndServeTLS("", "") when
+// srv.TLSConfig is set, except that a port conflict is reported as an
+// error wrapping ErrAddrInUse that names the address. Like its net/http
+// counterparts it returns http.ErrServerClosed after Shutdown.
+func ListenAndServe(srv *http.Server) error {
+	addr := srv.Addr
+	if addr == "" {
+		addr = ":http"
+		if srv.TLSConfig != nil {
+			addr = ":https"
+		}
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		if errors.Is(err, syscall.EADDRINUSE) {
+			return fmt.Errorf("%w: %s; is another server running?", ErrAddrInUse, addr)
+		}
+		return err
+	}
+	if srv.TLSConfig != nil {
+		return srv.ServeTLS(ln, "", "")
+	}
+	return srv.Serve(ln)
+}
@@ -0,0 +1,118 @@
+package web
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serve runs NewServer on a loopback port until the test ends.
+func serve(t *testing.T, h http.Handler, to Timeouts) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(ln.Addr().String(), h, to)
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	return ln.Addr().String()
+}
+
+func TestNewServerDefaults(t *testing.T) {
+	srv := NewServer(":0", http.NotFoundHandler(), Timeouts{Read: time.Minute})
+	if srv.ReadHeaderTimeout != DefaultTimeouts.ReadHeader {
+		t.Errorf("ReadHeaderTimeout = %v, want %v", srv.ReadHeaderTimeout, DefaultTimeouts.ReadHeader)
+	}
+	if srv.ReadTimeout != time.Minute {
+		t.Errorf("ReadTimeout = %v, want 1m", srv.ReadTimeout)
+	}
+	if srv.WriteTimeout != DefaultTimeouts.Write {
+		t.Errorf("WriteTimeout = %v, want %v", srv.WriteTimeout, DefaultTimeouts.Write)
+	}
+}
+
+func TestSlowHeadersAreCutOff(t *testing.T) {
+	addr := serve(t, http.NotFoundHandler(), Timeouts{ReadHeader: 100 * time.Millisecond, Read: time.Minute})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := io.WriteString(conn, "GET / HTTP/1.1\r\nHost: x\r\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The headers are never finished, so the server closes the connection.
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Fatal("server answered a request whose headers never ended")
+	} else if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		t.Fatal("server kept the connection open past ReadHeader")
+	}
+}
+
+func TestSlowBodyWithinReadTimeout(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, string(b))
+	})
+	addr := serve(t, h, Timeouts{ReadHeader: 100 * time.Millisecond, Read: 5 * time.Second})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "POST / HTTP/1.1\r\nHost: x\r\nContent-Length: 4\r\n\r\n")
+	// The body takes longer than ReadHeader but stays within Read.
+	for _, c := range "body" {
+		time.Sleep(50 * time.Millisecond)
+		io.WriteString(conn, string(c))
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(b), "body") {
+		t.Fatalf("got %d %q, want 200 with the body echoed", resp.StatusCode, b)
+	}
+}
+
+func TestListenAndServeAddrInUse(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	err = ListenAndServe(NewServer(ln.Addr().String(), http.NotFoundHandler(), DefaultTimeouts))
+	if !errors.Is(err, ErrAddrInUse) || !strings.Contains(err.Error(), ln.Addr().String()) {
+		t.Fatalf("ListenAndServe = %v, want ErrAddrInUse naming %s", err, ln.Addr())
+	}
+}
+
+func TestListenAndServeClosed(t *testing.T) {
+	srv := NewServer("127.0.0.1:0", http.NotFoundHandler(), DefaultTimeouts)
+	done := make(chan error, 1)
+	go func() { done <- ListenAndServe(srv) }()
+
+	srv.Close()
+	if err := <-done; !errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("ListenAndServe = %v, want ErrServerClosed", err)
+	}
+}
@@ -0,0 +1,64 @@
+package web
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+)
+
+// PreferredTLSVersion is the version clients are expected to negotiate.
+// Handshakes below MinVersion in TLSConfig fail; ones between the two
+// succeed but are logged.
+const PreferredTLSVersion = tls.VersionTLS13
+
+// TLSConfig loads the key pair and returns a server config that refuses
+// anything older than TLS 1.2 and logs, with the client address, every
+// handshake that settles on less than PreferredTLSVersion or on a cipher
+// suite Go lists as insecure. The certificate is already in the config, so
+// serve it with ListenAndServe, or ListenAndServeTLS("", "").
+func TLSConfig(certFile, keyFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load TLS key pair: %w", err)
+	}
+	base := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+	base.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		addr := hello.Conn.RemoteAddr().String()
+		cfg := base.Clone()
+		cfg.GetConfigForClient = nil
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			logWeakTLS(addr, cs)
+			return nil
+		}
+		return cfg, nil
+	}
+	return base, nil
+}
+
+func logWeakTLS(addr string, cs tls.ConnectionState) {
+	if cs.Version < PreferredTLSVersion {
+		log.Printf("tls: %s negotiated %s", addr, tlsVersionName(cs.Version))
+	}
+	for _, s := range tls.InsecureCipherSuites() {
+		if s.ID == cs.CipherSuite {
+			log.Printf("tls: %s negotiated insecure cipher suite %s", addr, s.Name)
+		}
+	}
+}
+
+func tlsVersionName(v uint16) string {
+	switch v {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	}
+	return "unknown TLS version"
+}
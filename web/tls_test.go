@@ -0,0 +1,159 @@
+package web
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// selfSigned writes a self-signed certificate for 127.0.0.1 and its key to
+// a temporary directory and returns their paths.
+func selfSigned(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// serveTLS runs NewServer with TLSConfig on a loopback port until the test
+// ends.
+func serveTLS(t *testing.T) string {
+	t.Helper()
+	cfg, err := TLSConfig(selfSigned(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(ln.Addr().String(), http.NotFoundHandler(), Timeouts{})
+	srv.TLSConfig = cfg
+	srv.ErrorLog = log.New(&bytes.Buffer{}, "", 0)
+	go srv.ServeTLS(ln, "", "")
+	t.Cleanup(func() { srv.Close() })
+	return ln.Addr().String()
+}
+
+// handshake dials addr with a client limited to TLS versions minVersion
+// through maxVersion and returns the negotiated version.
+func handshake(addr string, minVersion, maxVersion uint16) (uint16, error) {
+	conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true, MinVersion: minVersion, MaxVersion: maxVersion})
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	return conn.ConnectionState().Version, nil
+}
+
+// logBuffer is a bytes.Buffer the server goroutines can log to while the
+// test reads it.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// captureLog sends the standard logger to a logBuffer until the test ends.
+func captureLog(t *testing.T) *logBuffer {
+	t.Helper()
+	logs := &logBuffer{}
+	out := log.Writer()
+	log.SetOutput(logs)
+	t.Cleanup(func() { log.SetOutput(out) })
+	return logs
+}
+
+func TestTLSConfigRejectsTLS10(t *testing.T) {
+	addr := serveTLS(t)
+	if v, err := handshake(addr, tls.VersionTLS10, tls.VersionTLS10); err == nil {
+		t.Fatalf("TLS 1.0 handshake succeeded with %s", tlsVersionName(v))
+	}
+}
+
+func TestTLSConfigAcceptsTLS13(t *testing.T) {
+	logs := captureLog(t)
+	addr := serveTLS(t)
+
+	v, err := handshake(addr, tls.VersionTLS13, tls.VersionTLS13)
+	if err != nil {
+		t.Fatalf("TLS 1.3 handshake: %v", err)
+	}
+	if v != tls.VersionTLS13 {
+		t.Errorf("negotiated %s, want TLS 1.3", tlsVersionName(v))
+	}
+	if logs.String() != "" {
+		t.Errorf("TLS 1.3 client was logged: %s", logs.String())
+	}
+}
+
+func TestTLSConfigLogsTLS12(t *testing.T) {
+	logs := captureLog(t)
+	addr := serveTLS(t)
+
+	if _, err := handshake(addr, tls.VersionTLS12, tls.VersionTLS12); err != nil {
+		t.Fatalf("TLS 1.2 handshake: %v", err)
+	}
+	// The server logs on its side of the handshake; give it a moment.
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(logs.String(), "negotiated TLS 1.2") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if line := logs.String(); !strings.Contains(line, "127.0.0.1") || !strings.Contains(line, "negotiated TLS 1.2") {
+		t.Errorf("log = %q, want the client address and TLS 1.2", line)
+	}
+}
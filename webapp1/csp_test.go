@@ -0,0 +1,45 @@
+package main
+
+import (
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+var (
+	headerNonce = regexp.MustCompile(`script-src 'nonce-([^']+)'`)
+	scriptNonce = regexp.MustCompile(`<script nonce="([^"]+)">`)
+)
+
+// nonces renders the index page under withCSPNonce and returns the nonce
+// from the CSP header and the one in the page's inline script.
+func nonces(t *testing.T) (header, page string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	withCSPNonce(http.HandlerFunc(indexPage)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	h := headerNonce.FindStringSubmatch(rec.Header().Get("Content-Security-Policy"))
+	p := scriptNonce.FindStringSubmatch(rec.Body.String())
+	if h == nil || p == nil {
+		t.Fatalf("nonce missing: CSP %q, body %s", rec.Header().Get("Content-Security-Policy"), rec.Body)
+	}
+	// html/template escapes + in attributes; browsers decode it back.
+	return h[1], html.UnescapeString(p[1])
+}
+
+func TestCSPNonceMatchesTemplate(t *testing.T) {
+	header1, page1 := nonces(t)
+	if header1 != page1 {
+		t.Errorf("CSP nonce %q, script nonce %q, want them equal", header1, page1)
+	}
+
+	header2, page2 := nonces(t)
+	if header2 != page2 {
+		t.Errorf("CSP nonce %q, script nonce %q, want them equal", header2, page2)
+	}
+	if header1 == header2 {
+		t.Errorf("two requests got the same nonce %q", header1)
+	}
+}
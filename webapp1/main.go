@@ -0,0 +1,51 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"golang/web"
+)
+
+func main() {
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		renderTemplate(w, "index", struct {
+			page
+			Path string
+		}{newPage(r), r.URL.Path})
+	})
+	http.Handle("/feedback", newSubmitNonces(10000).middleware(http.HandlerFunc(feedback)))
+
+	srv := web.NewServer(":80", withCSPNonce(http.DefaultServeMux), web.DefaultTimeouts)
+	log.Fatal(web.ListenAndServe(srv))
+}
+
+// feedback shows the feedback form on GET and accepts it on POST. Each
+// rendering carries a fresh form nonce so the submission can only be
+// processed once.
+func feedback(w http.ResponseWriter, r *http.Request) {
+	data := struct {
+		page
+		FormNonce string
+		Sent      bool
+	}{page: newPage(r)}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		nonce, err := randomToken()
+		if err != nil {
+			log.Printf("form nonce: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		data.FormNonce = nonce
+	case http.MethodPost:
+		log.Printf("feedback: %q", r.PostFormValue("message"))
+		data.Sent = true
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	renderTemplate(w, "feedback", data)
+}
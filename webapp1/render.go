@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+)
+
+// page holds the values the layout itself needs. Page data structs embed
+// it so the layout can reach them as {{.Nonce}} and so on.
+type page struct {
+	Nonce string
+}
+
+func newPage(r *http.Request) page {
+	return page{Nonce: cspNonce(r.Context())}
+}
+
+// templateFS is compiled into the binary, so it runs from any working
+// directory.
+//
+//go:embed templates
+var templateFS embed.FS
+
+// templateSet parses layout.html and partials/*.html once and combines them
+// with each page under pages/. Pages fill the blocks the layout declares
+// ("title", "content"), so every page shares the same header and footer.
+type templateSet struct {
+	fsys fs.FS
+
+	once  sync.Once
+	pages map[string]*template.Template
+	err   error
+}
+
+var templates = &templateSet{fsys: mustSub(templateFS, "templates")}
+
+func mustSub(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+func (s *templateSet) load() (map[string]*template.Template, error) {
+	s.once.Do(func() {
+		s.pages, s.err = s.parse()
+	})
+	return s.pages, s.err
+}
+
+func (s *templateSet) parse() (map[string]*template.Template, error) {
+	base, err := template.ParseFS(s.fsys, "layout.html", "partials/*.html")
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := fs.Glob(s.fsys, "pages/*.html")
+	if err != nil {
+		return nil, err
+	}
+	pages := make(map[string]*template.Template, len(files))
+	for _, file := range files {
+		t, err := base.Clone()
+		if err != nil {
+			return nil, err
+		}
+		if t, err = t.ParseFS(s.fsys, file); err != nil {
+			return nil, err
+		}
+		pages[strings.TrimSuffix(path.Base(file), ".html")] = t
+	}
+	return pages, nil
+}
+
+// execute renders page name inside the layout into w.
+func (s *templateSet) execute(w *bytes.Buffer, name string, data interface{}) error {
+	pages, err := s.load()
+	if err != nil {
+		return err
+	}
+	t, ok := pages[name]
+	if !ok {
+		return fmt.Errorf("template %q not found", name)
+	}
+	return t.ExecuteTemplate(w, "layout", data)
+}
+
+// renderTemplate writes page name to w, buffering first so a template
+// error turns into a clean 500 instead of a half-written page. Values in
+// data are escaped by html/template for the context they appear in.
+func renderTemplate(w http.ResponseWriter, name string, data interface{}) {
+	var buf bytes.Buffer
+	if err := templates.execute(&buf, name, data); err != nil {
+		log.Printf("render %s: %v", name, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buf.WriteTo(w)
+}
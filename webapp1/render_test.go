@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// indexPage renders the index page the way main's "/" handler does.
+func indexPage(w http.ResponseWriter, r *http.Request) {
+	renderTemplate(w, "index", struct {
+		page
+		Path string
+	}{newPage(r), r.URL.Path})
+}
+
+func TestRenderTemplateWrapsPageInLayout(t *testing.T) {
+	rec := httptest.NewRecorder()
+	indexPage(rec, httptest.NewRequest(http.MethodGet, "/hello", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"<!DOCTYPE html>",                  // layout
+		"<title>Home</title>",              // page block in the layout
+		`<a href="/">webapp1</a>`,          // header partial
+		"<small>Served by webapp1</small>", // footer partial
+		"Hello, you've requested: /hello",  // page content
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page lacks %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestRenderTemplateUnknownPage(t *testing.T) {
+	rec := httptest.NewRecorder()
+	renderTemplate(rec, "missing", page{})
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "<html>") {
+		t.Error("a half-rendered page was sent")
+	}
+}
+
+func TestRenderTemplateEscapesPath(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.URL.Path = "/<script>alert(1)</script>"
+	indexPage(rec, req)
+
+	body := rec.Body.String()
+	if strings.Contains(body, "<script>alert(1)") {
+		t.Fatalf("injected markup rendered unescaped:\n%s", body)
+	}
+	if !strings.Contains(body, "&lt;script&gt;alert(1)&lt;/script&gt;") {
+		t.Errorf("page lacks the escaped path:\n%s", body)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+)
+
+// formNonceField is the hidden form field carrying the submit nonce.
+const formNonceField = "form_nonce"
+
+// submitNonces remembers the nonces of forms already submitted, so a
+// double click or a resubmit after reload is not processed twice. It keeps
+// the last max nonces; older ones are forgotten, which only matters for a
+// form resubmitted after max others.
+type submitNonces struct {
+	mu   sync.Mutex
+	used map[string]bool
+	ring []string
+	next int
+}
+
+func newSubmitNonces(max int) *submitNonces {
+	return &submitNonces{used: make(map[string]bool, max), ring: make([]string, max)}
+}
+
+// use records nonce and reports whether it was new.
+func (s *submitNonces) use(nonce string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.used[nonce] {
+		return false
+	}
+	if old := s.ring[s.next]; old != "" {
+		delete(s.used, old)
+	}
+	s.ring[s.next] = nonce
+	s.next = (s.next + 1) % len(s.ring)
+	s.used[nonce] = true
+	return true
+}
+
+// middleware lets each form nonce through once. A POST without one is
+// answered 400, a POST reusing one 409. Other methods pass untouched.
+func (s *submitNonces) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		nonce := r.PostFormValue(formNonceField)
+		if nonce == "" {
+			http.Error(w, "missing form nonce", http.StatusBadRequest)
+			return
+		}
+		if !s.use(nonce) {
+			http.Error(w, "this form has already been submitted", http.StatusConflict)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
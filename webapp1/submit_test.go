@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var formNonceInput = regexp.MustCompile(`name="form_nonce" value="([^"]+)"`)
+
+// postFeedback submits the feedback form with nonce through h.
+func postFeedback(h http.Handler, nonce string) *httptest.ResponseRecorder {
+	form := url.Values{"message": {"hello"}}
+	if nonce != "" {
+		form.Set(formNonceField, nonce)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/feedback", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestFeedbackRejectsResubmission(t *testing.T) {
+	h := newSubmitNonces(10).middleware(http.HandlerFunc(feedback))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/feedback", nil))
+	m := formNonceInput.FindStringSubmatch(rec.Body.String())
+	if m == nil {
+		t.Fatalf("no form nonce in %s", rec.Body)
+	}
+	nonce := html.UnescapeString(m[1])
+
+	if rec := postFeedback(h, nonce); rec.Code != http.StatusOK {
+		t.Fatalf("first submission = %d %s, want 200", rec.Code, rec.Body)
+	}
+	if rec := postFeedback(h, nonce); rec.Code != http.StatusConflict {
+		t.Errorf("resubmission = %d, want 409", rec.Code)
+	}
+	if rec := postFeedback(h, ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("submission without a nonce = %d, want 400", rec.Code)
+	}
+}
+
+func TestSubmitNoncesForgetsOldest(t *testing.T) {
+	s := newSubmitNonces(2)
+	for i := 0; i < 3; i++ {
+		if !s.use(fmt.Sprint(i)) {
+			t.Fatalf("nonce %d rejected on first use", i)
+		}
+	}
+	if !s.use("0") {
+		t.Error("nonce 0 still remembered past the store's bound")
+	}
+	if s.use("2") {
+		t.Error("nonce 2 accepted twice")
+	}
+}